@@ -1,216 +1,156 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"syscall"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
 	"time"
 
+	"github.com/machina/mosaico/internal/actions"
 	"github.com/machina/mosaico/internal/config"
+	"github.com/machina/mosaico/internal/daemon"
 	"github.com/machina/mosaico/internal/hotkeys"
-	"github.com/machina/mosaico/internal/strip"
+	"github.com/machina/mosaico/internal/layout"
 	"github.com/machina/mosaico/internal/wm"
 )
 
-var globalStrip *strip.Strip
-
-func applyLayout() {
-	gap := float64(10)
-
-	screenWidth, screenHeight, _ := wm.GetScreenBounds()
-	colWidth := screenWidth / float64(globalStrip.VisibleCount)
-	for i, col := range globalStrip.Columns {
-		x := (float64(i)-float64(globalStrip.ViewportStart))*colWidth + gap/2
-		w := colWidth - gap
-
-		// Calculate height per window
-
-		visible := x >= 0 && x+w <= screenWidth
-
-		for j, win := range col.Windows {
-			if visible {
-				winCount := len(col.Windows)
-				totalGaps := gap * float64(winCount-1)
-				winHeight := (screenHeight - gap - totalGaps) / float64(winCount)
-
-				winY := gap/2 + float64(j)*(winHeight+gap)
-				err := wm.SetPositionAndSize(win.PID, float64(x), float64(winY), float64(w), float64(winHeight))
-				if err != nil {
-					fmt.Printf("ERROR SetPositionAndSize %s: %v\n", win.Title, err)
-				} else {
-					fmt.Printf("Positioned %s at x=%.0f\n", win.Title, x)
-				}
-				wm.UnhideApp(win.PID)
-			} else {
-				wm.HideApp(win.PID)
-			}
-			fmt.Printf("Col %d: x=%.0f visible=%v, win: %s (PID=%d)\n", i, x, visible, win.Title, win.PID)
-		}
-	}
+// The event tap runs on the main thread's run loop, which is also where
+// NSWorkspace delivers its notifications.
+func init() {
+	runtime.LockOSThread()
 }
 
-func focusCurrentWindow() {
-	if len(globalStrip.Columns) == 0 {
+func main() {
+	dryRunFlag := flag.Bool("dry-run", false, "print the computed layout and exit without moving windows")
+	jsonFlag := flag.Bool("json", false, "with -dry-run, print JSON instead of a table")
+	explainFlag := flag.String("explain", "", "print which rule matches a window ID, or \"all\" windows, and exit")
+	actionsFlag := flag.Bool("actions", false, "list the actions bindings can use, and exit")
+	checkFlag := flag.Bool("check", false, "validate the config and bindings, and exit non-zero on problems")
+	flag.Parse()
+
+	if *actionsFlag {
+		if err := actions.WriteList(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
-	col := globalStrip.Columns[globalStrip.FocusedCol]
-	if len(col.Windows) == 0 {
-		return
+
+	// Reports go to stdout; log noise goes to stderr while there is one
+	var log io.Writer = os.Stdout
+	if *dryRunFlag || *explainFlag != "" {
+		log = os.Stderr
 	}
-	win := col.Windows[col.Focused]
-	wm.FocusApp(win.PID)
-}
 
-func watchWindows() {
-	ticker := time.NewTicker(2 * time.Second)
-	for range ticker.C {
-		globalStrip.Mutex.Lock()
-
-		changed := false
-		windows, _ := wm.GetWindowList()
-		currentPIDs := globalStrip.GetAllWindowPIDs()
-
-		// Add new windows
-		for _, w := range windows {
-			if !currentPIDs[w.PID] {
-				globalStrip.AddWindow(&strip.Window{
-					ID: w.ID, PID: w.PID, Title: w.OwnerName,
-				})
-				wm.GetWindow(w.PID) // warm cache
-				changed = true
-				fmt.Printf("New window: %s\n", w.OwnerName)
-			}
+	// Load config
+	cfg, loadErr := config.Load(config.Path())
+	if loadErr != nil {
+		fmt.Fprintf(log, "WARNING: %v, using the default config\n", loadErr)
+	}
+	problems := warn(log, hotkeys.Configure(cfg))
+	if _, err := layout.ParseParking(cfg.Layout.Parking); err != nil {
+		problems += warn(log, fmt.Errorf("%w, using hide", err))
+	}
+	if _, err := layout.ParseDragPolicy(cfg.Layout.Drag); err != nil {
+		problems += warn(log, fmt.Errorf("%w, using snap", err))
+	}
+	if cfg.Layout.Area != "visible" && cfg.Layout.Area != "full" {
+		problems += warn(log, fmt.Errorf("unknown layout area %q (want visible or full), using visible", cfg.Layout.Area))
+	}
+	for bundleID := range cfg.Apps {
+		if _, err := layout.ParseParking(cfg.Parking(bundleID)); err != nil {
+			problems += warn(log, fmt.Errorf("%s: %w, using hide", bundleID, err))
 		}
-
-		// Remove closed windows (check if process still running)
-		for pid := range currentPIDs {
-			if !isProcessRunning(pid) {
-				globalStrip.RemoveWindowByPID(pid)
-				changed = true
-				fmt.Printf("Removed window PID=%d\n", pid)
-			}
+	}
+	if *checkFlag {
+		if loadErr != nil || problems > 0 {
+			os.Exit(1)
 		}
+		fmt.Println("config OK")
+		return
+	}
 
-		globalStrip.Mutex.Unlock()
+	backend, err := wm.NewNative(log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
-		if changed {
-			applyLayout()
-		}
+	if *explainFlag != "" {
+		explain(daemon.New(backend, cfg, log), os.Stdout, *explainFlag)
+		return
 	}
-}
 
-func isProcessRunning(pid uint32) bool {
-	err := syscall.Kill(int(pid), 0)
-	return err == nil
-}
+	// Initialize one strip per display with current windows
+	d := daemon.New(backend, cfg, log)
+	d.StatePath = layout.StatePath()
+	d.Start()
 
-func main() {
-	// Load config
-	cfg, _ := config.Load("~/.config/mosaico/config.toml")
-	hotkeys.Configure(cfg.Hotkeys)
-
-	// Initialize strip with current windows
-	globalStrip = strip.New()
-	windows, _ := wm.GetWindowList()
-	fmt.Printf("Found %d windows\n", len(windows))
-	for _, w := range windows {
-		fmt.Printf("Window: ID=%d PID=%d Title=%s\n", w.ID, w.PID, w.OwnerName)
-		globalStrip.AddWindow(&strip.Window{
-			ID: w.ID, PID: w.PID, Title: w.OwnerName,
-		})
-	}
-	fmt.Printf("Strip has %d columns\n", len(globalStrip.Columns))
-
-	// Warm the cache
-	for _, col := range globalStrip.Columns {
-		for _, win := range col.Windows {
-			wm.GetWindow(win.PID)
+	if *dryRunFlag {
+		if err := d.DryRun(os.Stdout, *jsonFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
 		}
+		return
 	}
 
-	for i, col := range globalStrip.Columns {
-		if len(col.Windows) == 0 {
-			fmt.Printf("EMPTY COL %d before layout!\n", i)
-		}
-	}
+	d.ApplyLayout()
 
-	applyLayout()
-
-	// Set callback handlers
-	hotkeys.SetHandlers(hotkeys.Handlers{
-		ScrollLeft: func() {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.ScrollLeft()
-			applyLayout()
-			focusCurrentWindow()
-		},
-		ScrollRight: func() {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.ScrollRight()
-			applyLayout()
-			focusCurrentWindow()
-		},
-		FocusUp: func() {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.ScrollUp()
-			applyLayout()
-			focusCurrentWindow()
-		},
-		FocusDown: func() {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.ScrollDown()
-			applyLayout()
-			focusCurrentWindow()
-		},
-		MoveWindowRight: func() {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.MoveWindowRight()
-			applyLayout()
-			focusCurrentWindow()
-		},
-		MoveWindowLeft: func() {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.MoveWindowLeft()
-			applyLayout()
-			focusCurrentWindow()
-		},
-		MoveWindowUp: func() {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.MoveWindowUp()
-			applyLayout()
-			focusCurrentWindow()
-		},
-		MoveWindowDown: func() {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.MoveWindowDown()
-			applyLayout()
-			focusCurrentWindow()
-		},
-		JumpToColumn: func(n int) {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.JumpToColumn(n)
-			applyLayout()
-			focusCurrentWindow()
-		},
-		MoveToColumn: func(n int) {
-			globalStrip.Mutex.Lock()
-			defer globalStrip.Mutex.Unlock()
-			globalStrip.MoveToColumn(n)
-			applyLayout()
-			focusCurrentWindow()
-		},
+	hotkeys.SetRunner(d.Dispatch)
+	hotkeys.SetColumnCount(d.ColumnCount)
+	hotkeys.OnModeChanged(func(mode string) {
+		fmt.Printf("mode: %s\n", mode)
 	})
 
-	go watchWindows()
+	if events, err := wm.NewEventSource(log); err != nil {
+		fmt.Printf("WARNING: no window events (%v), polling\n", err)
+		go d.Watch(2 * time.Second)
+	} else {
+		go func() {
+			d.Run(events, 10*time.Second)
+			fmt.Fprintln(log, "Event source closed, polling instead")
+			d.Watch(2 * time.Second)
+		}()
+	}
 
 	// Start event tap (blocks forever)
-	hotkeys.StartEventTap()
+	if err := hotkeys.StartEventTap(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// warn prints each of the errors joined in err as a warning, and returns
+// how many there were.
+func warn(log io.Writer, err error) int {
+	if err == nil {
+		return 0
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		n := 0
+		for _, e := range joined.Unwrap() {
+			n += warn(log, e)
+		}
+		return n
+	}
+	fmt.Fprintf(log, "WARNING: %v\n", err)
+	return 1
+}
+
+func explain(d *daemon.Daemon, out io.Writer, arg string) {
+	var id uint64
+	if arg != "all" {
+		var err error
+		if id, err = strconv.ParseUint(arg, 10, 32); err != nil {
+			fmt.Fprintf(os.Stderr, "-explain: want a window ID or \"all\", got %q\n", arg)
+			os.Exit(2)
+		}
+	}
+	if err := d.Explain(out, uint32(id)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,69 @@
+// Command layout prints the frames mosaico would give every window for a
+// saved strip state, without moving anything. It builds on any OS.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/machina/mosaico/internal/config"
+	"github.com/machina/mosaico/internal/layout"
+)
+
+func main() {
+	statePath := flag.String("state", layout.StatePath(), "strip state saved by the daemon")
+	screen := flag.String("screen", "", "override every screen size, e.g. 2560x1440")
+	configPath := flag.String("config", config.Path(), "config file for parking strategies")
+	asJSON := flag.Bool("json", false, "print JSON instead of a table")
+	flag.Parse()
+
+	st, err := layout.LoadState(*statePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load state: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *screen != "" {
+		var w, h float64
+		if _, err := fmt.Sscanf(*screen, "%fx%f", &w, &h); err != nil || w <= 0 || h <= 0 {
+			fmt.Fprintf(os.Stderr, "bad -screen %q, want WIDTHxHEIGHT\n", *screen)
+			os.Exit(2)
+		}
+		// Lay the screens out side by side, like displays in a row
+		st.Screens = st.Screens[:0]
+		for i, s := range st.Displays.Strips {
+			st.Screens = append(st.Screens, layout.Screen{
+				DisplayID: s.DisplayID,
+				Frame:     layout.Rect{X: float64(i) * w, Width: w, Height: h},
+			})
+		}
+	}
+	if st.Gap == 0 {
+		st.Gap = 10
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config: %v\n", err)
+	}
+	strategyFor := func(p layout.Placement) layout.Parking {
+		strategy, err := layout.ParseParking(cfg.Parking(p.Window.BundleID))
+		if err != nil {
+			return layout.ParkHide
+		}
+		return strategy
+	}
+
+	entries := layout.DryRun(st, nil, strategyFor)
+	if *asJSON {
+		err = layout.WriteJSON(os.Stdout, entries)
+	} else {
+		err = layout.WriteTable(os.Stdout, entries)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	layout.WriteFailures(os.Stderr, st.Failures)
+}
@@ -4,15 +4,19 @@ import (
 	"fmt"
 	"math/rand/v2"
 	"os"
+	"runtime"
 	"runtime/trace"
-	"syscall"
+	"strconv"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/machina/mosaico/internal/actions"
 	"github.com/machina/mosaico/internal/config"
 	"github.com/machina/mosaico/internal/hotkeys"
+	"github.com/machina/mosaico/internal/rules"
 	"github.com/machina/mosaico/internal/strip"
 	"github.com/machina/mosaico/internal/wm"
 )
@@ -30,12 +34,42 @@ var (
 
 type WindowsChanged struct{}
 
+// keys maps TUI keys to actions.
+var keys = make(map[string]actions.Call)
+
+func init() {
+	for key, action := range map[string]string{
+		"h": "scroll-left", "l": "scroll-right", "k": "focus-up", "j": "focus-down",
+		"H": "move-left", "L": "move-right", "K": "move-up", "J": "move-down",
+		"w": "close-window", "m": "minimize-window",
+		"g": "jump-to-first-column", "G": "jump-to-last-column", "<": "page-left", ">": "page-right",
+	} {
+		keys[key] = mustParse(action)
+	}
+	for n := 1; n <= 9; n++ {
+		keys[strconv.Itoa(n)] = mustParse("jump-to-column " + strconv.Itoa(n))
+	}
+}
+
+func mustParse(action string) actions.Call {
+	call, err := actions.Parse(action)
+	if err != nil {
+		panic(err)
+	}
+	return call
+}
+
+// ModeChanged is sent when a hotkey enters or leaves a mode.
+type ModeChanged string
+
 type model struct {
+	backend      wm.Backend
 	strip        *strip.Strip
 	screenWidth  float64
 	screenHeight float64
 	colWidth     float64
 	gap          float64
+	mode         string
 	debug        string
 }
 
@@ -46,85 +80,28 @@ func (m model) Init() tea.Cmd {
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-
+		switch key := msg.String(); key {
 		case "ctrl+c", "q":
 			return m, tea.Quit
-
-		case "l":
-			fmt.Fprintf(os.Stderr, "BEFORE: FocusedCol=%d, ViewportStart=%d\n",
-				m.strip.FocusedCol, m.strip.ViewportStart)
-			m.strip.ScrollRight()
-			fmt.Fprintf(os.Stderr, "AFTER: FocusedCol=%d, ViewportStart=%d\n",
-				m.strip.FocusedCol, m.strip.ViewportStart)
-			m.applyLayout()
-		case "h":
-			m.strip.ScrollLeft()
-			m.applyLayout()
-			focusedCol := m.strip.Columns[m.strip.FocusedCol]
-			if len(focusedCol.Windows) > 0 {
-				wm.FocusApp(focusedCol.Windows[focusedCol.Focused].PID)
-			}
 		case "a":
 			window := &strip.Window{ID: rand.Uint32(), Title: "wooo"}
 			m.strip.AddWindow(window)
-		case "k":
-			m.strip.ScrollUp()
-			m.applyLayout()
-			focusedCol := m.strip.Columns[m.strip.FocusedCol]
-			if len(focusedCol.Windows) > 0 {
-				wm.FocusApp(focusedCol.Windows[focusedCol.Focused].PID)
-			}
-		case "j":
-			m.strip.ScrollDown()
-			m.applyLayout()
-			focusedCol := m.strip.Columns[m.strip.FocusedCol]
-			if len(focusedCol.Windows) > 0 {
-				wm.FocusApp(focusedCol.Windows[focusedCol.Focused].PID)
-			}
 		case "d":
 			m.strip.RemoveWindow()
 			m.applyLayout()
-		case "H":
-			m.strip.MoveWindowLeft()
-			m.applyLayout()
-		case "L":
-			m.strip.MoveWindowRight()
-			m.applyLayout()
-		case "K":
-			m.strip.MoveWindowUp()
-			m.applyLayout()
-		case "J":
-			m.strip.MoveWindowDown()
-			m.applyLayout()
-
-		}
-	case hotkeys.Command:
-		switch msg {
-		case hotkeys.CmdScrollLeft:
-			m.strip.ScrollLeft()
-			m.applyLayout()
-			focusedCol := m.strip.Columns[m.strip.FocusedCol]
-			if len(focusedCol.Windows) > 0 {
-				wm.FocusApp(focusedCol.Windows[focusedCol.Focused].PID)
+		default:
+			if call, ok := keys[key]; ok {
+				m.run(call)
 			}
-		case hotkeys.CmdScrollRight:
-			m.strip.ScrollRight()
-			m.applyLayout()
-			focusedCol := m.strip.Columns[m.strip.FocusedCol]
-			if len(focusedCol.Windows) > 0 {
-				wm.FocusApp(focusedCol.Windows[focusedCol.Focused].PID)
-			}
-		case hotkeys.CmdFocusDown:
-			m.strip.ScrollDown()
-			m.applyLayout()
-		case hotkeys.CmdFocusUp:
-			m.strip.ScrollUp()
-			m.applyLayout()
 		}
 
+	case actions.Call:
+		m.run(msg)
+
+	case ModeChanged:
+		m.mode = string(msg)
+
 	case WindowsChanged:
-		fmt.Fprintln(os.Stderr, "WindowsChanged received") // stderr won't mess up TUI
 		m.applyLayout()
 
 	}
@@ -140,16 +117,16 @@ func (m model) View() string {
 		return "No windows. Press 'a' to add."
 	}
 
-	visible := m.strip.GetVisibleColumns()
 	var columnBoxes []string
-	for i, col := range visible {
-		coldIndex := m.strip.ViewportStart + i
+	for _, coldIndex := range m.strip.ColumnsInViewport() {
+		col := m.strip.Columns[coldIndex]
 		var windowBoxes []string
 		for o, win := range col.Windows {
+			label := win.AppName + "\n" + win.Title
 			if o == col.Focused && coldIndex == m.strip.FocusedCol {
-				windowBoxes = append(windowBoxes, focusedWindowStyle.Render(win.Title))
+				windowBoxes = append(windowBoxes, focusedWindowStyle.Render(label))
 			} else {
-				windowBoxes = append(windowBoxes, windowStyle.Render(win.Title))
+				windowBoxes = append(windowBoxes, windowStyle.Render(label))
 			}
 		}
 		stack := lipgloss.JoinVertical(lipgloss.Left, windowBoxes...)
@@ -160,41 +137,70 @@ func (m model) View() string {
 		}
 	}
 
-	debug := fmt.Sprintf("\nscreen: %v x %v | colWidth: %v | gap: %v\n",
-		m.screenWidth, m.screenHeight, m.colWidth, m.gap)
+	debug := fmt.Sprintf("\nmode: %s | screen: %v x %v | colWidth: %v | gap: %v\n",
+		m.mode, m.screenWidth, m.screenHeight, m.colWidth, m.gap)
 
 	return lipgloss.JoinHorizontal(lipgloss.Top, columnBoxes...) + "\n" + debug + "\n" + m.debug
 }
 
+// env is the actions.Env of the TUI: its single strip, as one display.
+type env struct{ m *model }
+
+func (e env) Displays() *strip.Displays {
+	return &strip.Displays{Strips: []*strip.Strip{e.m.strip}}
+}
+func (e env) Backend() wm.Backend { return e.m.backend }
+func (e env) Forget(id uint32)    { e.m.strip.RemoveWindowByID(id) }
+
+// Logf shows the message under the strip.
+func (e env) Logf(format string, args ...any) {
+	e.m.debug = strings.TrimSpace(fmt.Sprintf(format, args...))
+}
+
+// run runs an action, then lays out and focuses the focused window.
+func (m *model) run(call actions.Call) {
+	m.debug = ""
+	if err := actions.Run(env{m}, call); err != nil {
+		m.debug = err.Error()
+	}
+	m.applyLayout()
+	m.focusCurrentWindow()
+}
+
 func (m *model) applyLayout() {
 	gap := float64(10)
 
-	screenWidth, screenHeight, err := wm.GetScreenBounds()
-	if err != nil {
-		// handle error, for now just hardcode
-		screenWidth = 2560
-		screenHeight = 1440
+	// The main display, minus the menu bar and Dock
+	screen := wm.Frame{Width: 2560, Height: 1440}
+	if displays, err := m.backend.Displays(); err == nil {
+		for _, d := range displays {
+			if d.Main {
+				screen = d.Usable()
+			}
+		}
 	}
+	screenWidth, screenHeight := screen.Width, screen.Height
 
-	colWidth := screenWidth / float64(m.strip.VisibleCount)
+	m.strip.SetViewportWidth(screenWidth)
+	colWidth := m.strip.ColumnWidth(m.strip.FocusedCol)
 	for i, col := range m.strip.Columns {
-		x := (float64(i)-float64(m.strip.ViewportStart))*colWidth + gap/2
-		w := colWidth - gap
+		x := screen.X + m.strip.ColumnX(i) - m.strip.ViewportOffset + gap/2
+		w := m.strip.ColumnWidth(i) - gap
 
 		// Calculate height per window
 		winCount := len(col.Windows)
 		totalGaps := gap * float64(winCount-1)
 		winHeight := (screenHeight - gap - totalGaps) / float64(winCount)
 
-		if x < 0 || x+w > screenWidth {
-			wm.HideApp(col.Windows[0].PID)
+		if x < screen.X || x+w > screen.X+screenWidth {
+			m.backend.HideApp(col.Windows[0].PID)
 		} else {
-			wm.UnhideApp(col.Windows[0].PID)
+			m.backend.UnhideApp(col.Windows[0].PID)
 		}
 
 		for j, win := range col.Windows {
-			winY := gap/2 + float64(j)*(winHeight+gap)
-			wm.SetPositionAndSize(win.PID, float64(x), float64(winY), float64(w), float64(winHeight))
+			winY := screen.Y + gap/2 + float64(j)*(winHeight+gap)
+			m.backend.SetFrame(win.PID, win.ID, wm.Frame{X: x, Y: winY, Width: w, Height: winHeight})
 		}
 	}
 	m.screenWidth = screenWidth
@@ -203,71 +209,127 @@ func (m *model) applyLayout() {
 	m.gap = gap
 }
 
-func isProcessRunning(pid uint32) bool {
-	// kill -0 checks if process exists without killing it
-	err := syscall.Kill(int(pid), 0)
-	return err == nil
+func (m model) focusCurrentWindow() {
+	if len(m.strip.Columns) == 0 {
+		return
+	}
+	focusedCol := m.strip.Columns[m.strip.FocusedCol]
+	if len(focusedCol.Windows) > 0 {
+		win := focusedCol.Windows[focusedCol.Focused]
+		m.backend.Focus(win.PID, win.ID)
+	}
 }
 
-func watchWindows(p *tea.Program, s *strip.Strip) {
+// watchWindows syncs the strip on every window event, and every interval
+// in case events were missed or aren't available.
+func watchWindows(p *tea.Program, backend wm.Backend, events <-chan wm.Event, engine *rules.Engine, s *strip.Strip) {
 	ticker := time.NewTicker(10 * time.Second)
-	for range ticker.C {
-		start := time.Now()
-		windows, _ := wm.GetWindowList()
-		fmt.Fprintf(os.Stderr, "GetWindowList took: %v\n", time.Since(start))
-		currentPIDs := s.GetAllWindowPIDs()
-		changed := false
-
-		newPIDs := make(map[uint32]bool)
-		for _, w := range windows {
-			// fmt.Printf("ID: %d, PID: %d, Name: %s\n", w.ID, w.PID, w.OwnerName)
-			newPIDs[w.PID] = true
-			if !currentPIDs[w.PID] {
-				s.AddWindow(&strip.Window{
-					ID:    w.ID,
-					PID:   w.PID,
-					Title: w.OwnerName,
-				})
-				changed = true
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			// An app quitting has no window ID, so it takes a full sync
+			if e.Kind == wm.EventDestroyed && e.ID != 0 {
+				s.RemoveWindowByID(e.ID)
+				p.Send(WindowsChanged{})
+				continue
 			}
+			if e.Kind != wm.EventCreated && e.Kind != wm.EventDestroyed {
+				continue
+			}
+		case <-ticker.C:
+		}
+		syncWindows(p, backend, engine, s)
+	}
+}
+
+func syncWindows(p *tea.Program, backend wm.Backend, engine *rules.Engine, s *strip.Strip) {
+	windows, _ := backend.Windows()
+	known := s.GetAllWindowIDs()
+	changed := false
+
+	for _, w := range windows {
+		if known[w.ID] || !w.Tileable() || !engine.Match(w).Tiled() {
+			continue
 		}
+		s.AddWindow(&strip.Window{
+			ID:       w.ID,
+			PID:      w.PID,
+			BundleID: w.BundleID,
+			AppName:  w.OwnerName,
+			Title:    w.Title,
+		})
+		changed = true
+	}
 
-		for pid := range currentPIDs {
-			if !isProcessRunning(pid) {
-				s.RemoveWindowByPID(pid)
+	// Hidden windows are off screen, so check against every window
+	if existing, err := backend.WindowIDs(); err == nil {
+		for id := range known {
+			if !existing[id] {
+				s.RemoveWindowByID(id)
 				changed = true
 			}
 		}
+	}
 
-		if changed {
-			p.Send(WindowsChanged{})
-		}
+	if changed {
+		p.Send(WindowsChanged{})
 	}
 }
 
+// The event tap runs on the main thread's run loop, which is also where
+// NSWorkspace delivers its notifications.
+func init() {
+	runtime.LockOSThread()
+}
+
 func main() {
 	f, _ := os.Create("trace.out")
-	defer f.Close()
 	trace.Start(f)
-	defer trace.Stop()
+	backend, err := wm.NewNative(os.Stderr)
+	if err != nil {
+		fmt.Printf("!!! %v\n", err)
+		os.Exit(1)
+	}
 	s := strip.New()
-	p := tea.NewProgram(model{strip: s})
+	p := tea.NewProgram(model{backend: backend, strip: s, mode: hotkeys.DefaultMode})
 
-	go watchWindows(p, s)
+	cfg, _ := config.Load(config.Path())
+	engine, err := rules.New(cfg.Rules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		engine, _ = rules.New(nil)
+	}
+	var events <-chan wm.Event
+	if src, err := wm.NewEventSource(os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "no window events: %v\n", err)
+	} else {
+		events = src.Events()
+	}
+	go watchWindows(p, backend, events, engine, s)
 
+	if err := hotkeys.Configure(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+	}
+	hotkeys.SetRunner(func(c actions.Call) { p.Send(c) })
+	hotkeys.OnModeChanged(func(mode string) { p.Send(ModeChanged(mode)) })
 	go func() {
-		for cmd := range hotkeys.Commands {
-			p.Send(cmd)
+		_, err := p.Run()
+		trace.Stop()
+		f.Close()
+		if err != nil {
+			fmt.Printf("!!! there's been an error: %v", err)
+			os.Exit(1)
 		}
+		os.Exit(0)
 	}()
 
-	cfg, _ := config.Load("~/.config/mosaico/config.toml")
-	hotkeys.Configure(cfg.Hotkeys)
-	go hotkeys.StartEventTap()
-
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("!!! there's been an error: %v", err)
-		os.Exit(1)
+	// Start event tap (blocks forever)
+	if err := hotkeys.StartEventTap(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		select {} // the periodic sync still notices apps launching
 	}
-
 }
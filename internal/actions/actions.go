@@ -0,0 +1,206 @@
+// Package actions is the registry of everything mosaico can be told to do.
+// Hotkeys, the TUI and external commands all name actions from here and
+// run them through Run.
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/machina/mosaico/internal/strip"
+	"github.com/machina/mosaico/internal/wm"
+)
+
+// Env is what actions run against. Callers give actions exclusive access
+// to the strips for the duration of Run, and lay them out afterwards.
+type Env interface {
+	Displays() *strip.Displays
+	Backend() wm.Backend
+	// Forget drops a window that closed or left the strip.
+	Forget(id uint32)
+	// Logf reports what an action did, such as a window it closed.
+	Logf(format string, args ...any)
+}
+
+// Type is the type of an action argument.
+type Type int
+
+const (
+	// Int is a positive whole number.
+	Int Type = iota
+	// Float is any number.
+	Float
+	String
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int:
+		return "count"
+	case Float:
+		return "number"
+	case String:
+		return "string"
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// Param describes an action argument.
+type Param struct {
+	Name string
+	Type Type
+}
+
+// Action is a named thing mosaico can do.
+type Action struct {
+	Name        string
+	Description string
+	Params      []Param
+	// Repeat is set for actions that keep going while their hotkey is held.
+	Repeat bool
+	Run    func(env Env, args Args) error
+}
+
+// Usage is the action name followed by its parameters, like
+// "move-to-column N".
+func (a *Action) Usage() string {
+	parts := []string{a.Name}
+	for _, p := range a.Params {
+		parts = append(parts, p.Name)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Args holds parsed arguments, an int, float64 or string each, matching
+// the action's Params.
+type Args []any
+
+func (a Args) Int(i int) int       { return a[i].(int) }
+func (a Args) Float(i int) float64 { return a[i].(float64) }
+func (a Args) String(i int) string { return a[i].(string) }
+
+// Call is an action with its arguments.
+type Call struct {
+	Action *Action
+	Args   Args
+	// Count runs a repeating action that many times, like a vim count.
+	// Zero runs it once.
+	Count int
+}
+
+func (c Call) String() string {
+	if c.Action == nil {
+		return ""
+	}
+	var parts []string
+	if c.Count > 0 {
+		parts = append(parts, strconv.Itoa(c.Count))
+	}
+	parts = append(parts, c.Action.Name)
+	for _, arg := range c.Args {
+		parts = append(parts, fmt.Sprint(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+var registry = make(map[string]*Action)
+
+// register adds actions to the registry. Names must be unique.
+func register(list ...*Action) {
+	for _, a := range list {
+		if _, ok := registry[a.Name]; ok {
+			panic("actions: duplicate action " + a.Name)
+		}
+		registry[a.Name] = a
+	}
+}
+
+// Lookup returns the action with the given name, or nil.
+func Lookup(name string) *Action {
+	return registry[name]
+}
+
+// List returns every action, sorted by name.
+func List() []*Action {
+	list := make([]*Action, 0, len(registry))
+	for _, a := range registry {
+		list = append(list, a)
+	}
+	slices.SortFunc(list, func(a, b *Action) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+// Parse parses an action with its arguments, such as "move-to-column 3",
+// optionally after a count for repeating actions, as in "3 scroll-right".
+func Parse(s string) (Call, error) {
+	fields := strings.Fields(s)
+	count := 0
+	if len(fields) > 0 {
+		if n, err := strconv.Atoi(fields[0]); err == nil {
+			if n < 1 {
+				return Call{}, fmt.Errorf("bad count %q, want a positive number", fields[0])
+			}
+			count, fields = n, fields[1:]
+		}
+	}
+	if len(fields) == 0 {
+		return Call{}, errors.New("empty action")
+	}
+	a := Lookup(fields[0])
+	if a == nil {
+		return Call{}, fmt.Errorf("unknown action %q", fields[0])
+	}
+	if len(fields)-1 != len(a.Params) {
+		return Call{}, fmt.Errorf("%s takes %d argument(s), got %d (usage: %s)",
+			a.Name, len(a.Params), len(fields)-1, a.Usage())
+	}
+	args := make(Args, len(a.Params))
+	for i, p := range a.Params {
+		f := fields[i+1]
+		switch p.Type {
+		case Int:
+			n, err := strconv.Atoi(f)
+			if err != nil || n < 1 {
+				return Call{}, fmt.Errorf("%s: bad %s %q, want a positive number", a.Name, p.Name, f)
+			}
+			args[i] = n
+		case Float:
+			x, err := strconv.ParseFloat(f, 64)
+			if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
+				return Call{}, fmt.Errorf("%s: bad %s %q, want a number", a.Name, p.Name, f)
+			}
+			args[i] = x
+		case String:
+			args[i] = f
+		}
+	}
+	if count > 0 && !a.Repeat {
+		return Call{}, fmt.Errorf("%s doesn't repeat, so it takes no count", a.Name)
+	}
+	return Call{Action: a, Args: args, Count: count}, nil
+}
+
+// Run runs a call against env, Count times for a repeating action.
+func Run(env Env, c Call) error {
+	for range max(c.Count, 1) {
+		if err := c.Action.Run(env, c.Args); err != nil {
+			return fmt.Errorf("%s: %w", c, err)
+		}
+	}
+	return nil
+}
+
+// WriteList prints every action with its parameters and description.
+func WriteList(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, a := range List() {
+		fmt.Fprintf(tw, "%s\t%s\n", a.Usage(), a.Description)
+	}
+	return tw.Flush()
+}
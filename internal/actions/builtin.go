@@ -0,0 +1,190 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/machina/mosaico/internal/strip"
+)
+
+// quitConfirm is how long quit-app waits for the second call.
+const quitConfirm = 3 * time.Second
+
+// quitArmed is the app the first quit-app call was for. Actions run one
+// at a time, so it needs no lock.
+var (
+	quitArmed uint32
+	quitUntil time.Time
+)
+
+var errNoWindow = errors.New("no focused window")
+
+var column = []Param{{Name: "N", Type: Int}}
+
+// onStrip makes an action from an operation on the focused strip.
+func onStrip(name, description string, f func(s *strip.Strip)) *Action {
+	return &Action{Name: name, Description: description, Run: func(env Env, _ Args) error {
+		if s := env.Displays().FocusedStrip(); s != nil {
+			f(s)
+		}
+		return nil
+	}}
+}
+
+// onColumn is onStrip for operations taking a column number.
+func onColumn(name, description string, f func(s *strip.Strip, n int)) *Action {
+	return &Action{Name: name, Description: description, Params: column, Run: func(env Env, args Args) error {
+		if s := env.Displays().FocusedStrip(); s != nil {
+			f(s, args.Int(0))
+		}
+		return nil
+	}}
+}
+
+// onDisplays makes an action from an operation on every display's strips.
+func onDisplays(name, description string, f func(d *strip.Displays)) *Action {
+	return &Action{Name: name, Description: description, Run: func(env Env, _ Args) error {
+		f(env.Displays())
+		return nil
+	}}
+}
+
+// repeating marks actions that keep going while their hotkey is held.
+func repeating(list ...*Action) []*Action {
+	for _, a := range list {
+		a.Repeat = true
+	}
+	return list
+}
+
+func init() {
+	register(repeating(
+		onStrip("scroll-left", "Focus the column to the left", (*strip.Strip).ScrollLeft),
+		onStrip("scroll-right", "Focus the column to the right", (*strip.Strip).ScrollRight),
+		onStrip("focus-up", "Focus the window above in the column", (*strip.Strip).ScrollUp),
+		onStrip("focus-down", "Focus the window below in the column", (*strip.Strip).ScrollDown),
+		onStrip("move-left", "Move the window to the column on the left", (*strip.Strip).MoveWindowLeft),
+		onStrip("move-right", "Move the window to the column on the right", (*strip.Strip).MoveWindowRight),
+		onStrip("move-up", "Move the window up in its column", (*strip.Strip).MoveWindowUp),
+		onStrip("move-down", "Move the window down in its column", (*strip.Strip).MoveWindowDown),
+		onStrip("page-left", "Focus the column a screenful to the left",
+			func(s *strip.Strip) { s.PageBy(-1) }),
+		onStrip("page-right", "Focus the column a screenful to the right",
+			func(s *strip.Strip) { s.PageBy(1) }),
+	)...)
+	register(
+		&Action{
+			Name:        "scroll-by",
+			Description: "Pan the viewport PX pixels right (left if negative), keeping focus in view",
+			Params:      []Param{{Name: "PX", Type: Float}},
+			Run: func(env Env, args Args) error {
+				if s := env.Displays().FocusedStrip(); s != nil {
+					s.Pan(args.Float(0))
+				}
+				return nil
+			},
+		},
+		onColumn("jump-to-column", "Focus column N", (*strip.Strip).JumpToColumn),
+		onColumn("move-to-column", "Move the window to column N", (*strip.Strip).MoveToColumn),
+		onStrip("jump-to-first-column", "Focus the first column", func(s *strip.Strip) { s.JumpToColumn(1) }),
+		onStrip("jump-to-last-column", "Focus the last column",
+			func(s *strip.Strip) { s.JumpToColumn(len(s.Columns)) }),
+		onStrip("move-to-first-column", "Move the window to the first column",
+			func(s *strip.Strip) { s.MoveToColumn(1) }),
+		onStrip("move-to-last-column", "Move the window to the last column",
+			func(s *strip.Strip) { s.MoveToColumn(len(s.Columns)) }),
+
+		onDisplays("focus-next-display", "Focus the display to the right", (*strip.Displays).FocusNext),
+		onDisplays("focus-prev-display", "Focus the display to the left", (*strip.Displays).FocusPrev),
+		onDisplays("move-window-next-display", "Move the window to the display to the right",
+			func(d *strip.Displays) { d.MoveWindowToDisplay(1) }),
+		onDisplays("move-window-prev-display", "Move the window to the display to the left",
+			func(d *strip.Displays) { d.MoveWindowToDisplay(-1) }),
+		onDisplays("move-column-next-display", "Move the column to the display to the right",
+			func(d *strip.Displays) { d.MoveColumnToDisplay(1) }),
+		onDisplays("move-column-prev-display", "Move the column to the display to the left",
+			func(d *strip.Displays) { d.MoveColumnToDisplay(-1) }),
+
+		&Action{Name: "close-window", Description: "Close the focused window", Run: closeWindow},
+		&Action{Name: "close-column", Description: "Close every window in the focused column", Run: closeColumn},
+		&Action{Name: "minimize-window", Description: "Minimize the window and take it out of the strip", Run: minimizeWindow},
+		&Action{Name: "quit-app", Description: "Force quit the focused app; call twice to confirm", Run: quitApp},
+	)
+}
+
+// closeWindows presses the close button of each window and takes the ones
+// that closed out of the strip right away; their destroyed events find
+// nothing left to do. A window kept open, say by an unsaved-changes sheet,
+// comes back with the next reconcile.
+func closeWindows(env Env, wins []*strip.Window) {
+	for _, win := range wins {
+		if err := env.Backend().Close(win.PID, win.ID); err != nil {
+			env.Logf("ERROR Close %s: %v\n", win.Label(), err)
+			continue
+		}
+		env.Logf("Closed %s\n", win.Label())
+		env.Forget(win.ID)
+	}
+}
+
+func closeWindow(env Env, _ Args) error {
+	win := env.Displays().FocusedWindow()
+	if win == nil {
+		return errNoWindow
+	}
+	closeWindows(env, []*strip.Window{win})
+	return nil
+}
+
+func closeColumn(env Env, _ Args) error {
+	s := env.Displays().FocusedStrip()
+	if s == nil || len(s.Columns) == 0 {
+		return errNoWindow
+	}
+	// closeWindows edits the column, so work on a copy
+	closeWindows(env, append([]*strip.Window(nil), s.Columns[s.FocusedCol].Windows...))
+	return nil
+}
+
+// minimizeWindow takes the window out of the strip. It is tiled again as a
+// new window once restored.
+func minimizeWindow(env Env, _ Args) error {
+	win := env.Displays().FocusedWindow()
+	if win == nil {
+		return errNoWindow
+	}
+	if err := env.Backend().SetMinimized(win.PID, win.ID, true); err != nil {
+		return fmt.Errorf("minimize %s: %w", win.Label(), err)
+	}
+	env.Logf("Minimized %s\n", win.Label())
+	env.Forget(win.ID)
+	return nil
+}
+
+// quitApp force-quits the app owning the focused window. The first call
+// only arms it; a second call within quitConfirm confirms.
+func quitApp(env Env, _ Args) error {
+	d := env.Displays()
+	win := d.FocusedWindow()
+	if win == nil {
+		return errNoWindow
+	}
+	if quitArmed != win.PID || time.Now().After(quitUntil) {
+		quitArmed, quitUntil = win.PID, time.Now().Add(quitConfirm)
+		env.Logf("Press again within %v to force quit %s\n", quitConfirm, win.AppName)
+		return nil
+	}
+	quitArmed = 0
+
+	if err := env.Backend().Quit(win.PID, true); err != nil {
+		return fmt.Errorf("quit %s: %w", win.AppName, err)
+	}
+	env.Logf("Force quit %s\n", win.AppName)
+	for id := range d.GetAllWindowIDs() {
+		if w := d.Window(id); w != nil && w.PID == win.PID {
+			env.Forget(id)
+		}
+	}
+	return nil
+}
@@ -2,34 +2,197 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
 
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	Hotkeys HotkeyConfig `toml:"hotkeys"`
+	Hotkeys  HotkeyConfig             `toml:"hotkeys"`
+	Layout   LayoutConfig             `toml:"layout"`
+	Displays map[string]DisplayConfig `toml:"displays"`
+	Apps     map[string]AppConfig     `toml:"apps"`
+	Rules    []RuleConfig             `toml:"rules"`
+	// Bindings maps key combos to actions, e.g. "ctrl+alt+3" =
+	// "move-to-column 3". They add to and override [hotkeys].
+	Bindings map[string]string `toml:"bindings"`
+	// Modes are named keymaps, entered with a "mode NAME" binding, where
+	// bare keys work until escape or the mode timeout.
+	Modes  map[string]map[string]string `toml:"modes"`
+	Scroll ScrollConfig                 `toml:"scroll"`
 }
 
 type HotkeyConfig struct {
-	Modifier     string `toml:"modifier"`
-	MoveModifier string `toml:"move_modifier"`
-	ScrollLeft   string `toml:"scroll_left"`
-	ScrollRight  string `toml:"scroll_right"`
-	FocusUp      string `toml:"focus_up"`
-	FocusDown    string `toml:"focus_down"`
+	Modifier              string `toml:"modifier"`
+	MoveModifier          string `toml:"move_modifier"`
+	ScrollLeft            string `toml:"scroll_left"`
+	ScrollRight           string `toml:"scroll_right"`
+	FocusUp               string `toml:"focus_up"`
+	FocusDown             string `toml:"focus_down"`
+	NextDisplay           string `toml:"next_display"`
+	PrevDisplay           string `toml:"prev_display"`
+	MoveColumnNextDisplay string `toml:"move_column_next_display"`
+	MoveColumnPrevDisplay string `toml:"move_column_prev_display"`
+	// With the move modifier, close_window closes the whole column
+	CloseWindow    string `toml:"close_window"`
+	MinimizeWindow string `toml:"minimize_window"`
+	// QuitApp force-quits the focused app; press it twice to confirm
+	QuitApp string `toml:"quit_app"`
+
+	// ModeTimeout is how many idle seconds a mode lasts; 0 keeps it until
+	// escape. ChordTimeout is how long to wait for the next key of a
+	// sequence.
+	ModeTimeout  float64 `toml:"mode_timeout"`
+	ChordTimeout float64 `toml:"chord_timeout"`
+
+	// Repeat is what holding a scroll or move hotkey does: "accelerate"
+	// (repeat at repeat_rate per second, rising to repeat_max_rate over
+	// repeat_ramp seconds), "os" (at the system key repeat rate) or "off".
+	Repeat        string  `toml:"repeat"`
+	RepeatRate    float64 `toml:"repeat_rate"`
+	RepeatMaxRate float64 `toml:"repeat_max_rate"`
+	RepeatRamp    float64 `toml:"repeat_ramp"`
+}
+
+// ScrollConfig is how the scroll wheel and trackpad scroll the strip.
+type ScrollConfig struct {
+	// Mode is "column" (a column at a time), "pixel" (smoothly) or "off".
+	Mode string `toml:"mode"`
+	// Modifiers make any scroll drive the strip while held.
+	Modifiers string `toml:"modifiers"`
+	// Swipe takes horizontal trackpad swipes without modifiers too.
+	Swipe       bool    `toml:"swipe"`
+	Sensitivity float64 `toml:"sensitivity"`
+	Invert      bool    `toml:"invert"`
+}
+
+type LayoutConfig struct {
+	VisibleCount int `toml:"visible_count"`
+	// Parking is how off-screen windows are hidden: "hide" (the whole
+	// app), "edge" (moved past the screen edge) or "minimize".
+	Parking string `toml:"parking"`
+	// Drag is what happens to a tiled window dragged with the mouse:
+	// "snap" (back into place), "reorder" (to where it was dropped) or
+	// "float" (out of the strip).
+	Drag string `toml:"drag"`
+	// Area is what windows are laid out on: "visible" (the display minus
+	// the menu bar and Dock) or "full".
+	Area string `toml:"area"`
+	// Padding is extra space kept free on every display, for instance for
+	// a custom status bar.
+	Padding Padding `toml:"padding"`
+}
+
+type Padding struct {
+	Top    float64 `toml:"top"`
+	Bottom float64 `toml:"bottom"`
+	Left   float64 `toml:"left"`
+	Right  float64 `toml:"right"`
+}
+
+// AppConfig overrides settings for one app, keyed by bundle ID.
+type AppConfig struct {
+	Parking string `toml:"parking"`
+	// Hotkeys is what happens to mosaico's hotkeys while the app is
+	// frontmost: "consume" (run them and keep them from the app), "share"
+	// (run them and pass them on) or "ignore" (leave them to the app).
+	Hotkeys string `toml:"hotkeys"`
+}
+
+// RuleConfig is a [[rules]] entry. Every match field that is set must
+// match; the first matching rule wins.
+type RuleConfig struct {
+	BundleID  string  `toml:"bundle_id"`
+	App       string  `toml:"app"`
+	Title     string  `toml:"title"` // regular expression
+	Role      string  `toml:"role"`
+	Subrole   string  `toml:"subrole"`
+	MinWidth  float64 `toml:"min_width"`
+	MaxWidth  float64 `toml:"max_width"`
+	MinHeight float64 `toml:"min_height"`
+	MaxHeight float64 `toml:"max_height"`
+
+	Ignore        bool    `toml:"ignore"`
+	Float         bool    `toml:"float"`
+	OpenAtColumn  int     `toml:"open_at_column"`
+	OpenOnDisplay string  `toml:"open_on_display"`
+	ColumnWidth   float64 `toml:"column_width"` // pixels, or a fraction of the screen if <= 1
+	StackWithApp  bool    `toml:"stack_with_app"`
+}
+
+// DisplayConfig overrides layout settings for one display. Displays are
+// keyed by their CoreGraphics display ID, or "main" for the main display.
+type DisplayConfig struct {
+	VisibleCount int `toml:"visible_count"`
 }
 
 func Default() Config {
 	return Config{
 		Hotkeys: HotkeyConfig{
-			Modifier:     "ctrl+cmd+alt",
-			MoveModifier: "shift+ctrl+cmd+alt",
-			ScrollLeft:   "h",
-			ScrollRight:  "l",
-			FocusUp:      "k",
-			FocusDown:    "j",
+			Modifier:              "ctrl+cmd+alt",
+			MoveModifier:          "shift+ctrl+cmd+alt",
+			ScrollLeft:            "h",
+			ScrollRight:           "l",
+			FocusUp:               "k",
+			FocusDown:             "j",
+			NextDisplay:           ".",
+			PrevDisplay:           ",",
+			MoveColumnNextDisplay: "]",
+			MoveColumnPrevDisplay: "[",
+			CloseWindow:           "w",
+			MinimizeWindow:        "m",
+			QuitApp:               "q",
+			ModeTimeout:           10,
+			ChordTimeout:          1,
+			Repeat:                "accelerate",
+			RepeatRate:            8,
+			RepeatMaxRate:         30,
+			RepeatRamp:            1.5,
 		},
+		Scroll: ScrollConfig{
+			Mode:        "column",
+			Modifiers:   "ctrl+cmd+alt",
+			Sensitivity: 1,
+		},
+		Layout: LayoutConfig{
+			VisibleCount: 2,
+			Parking:      "hide",
+			Drag:         "snap",
+			Area:         "visible",
+		},
+	}
+}
+
+// VisibleCount returns the number of columns shown at once on a display.
+func (c Config) VisibleCount(displayID uint32, main bool) int {
+	if d, ok := c.Displays[strconv.FormatUint(uint64(displayID), 10)]; ok && d.VisibleCount > 0 {
+		return d.VisibleCount
+	}
+	if d, ok := c.Displays["main"]; ok && main && d.VisibleCount > 0 {
+		return d.VisibleCount
+	}
+	if c.Layout.VisibleCount > 0 {
+		return c.Layout.VisibleCount
+	}
+	return 2
+}
+
+// Parking returns the parking strategy name for an app.
+func (c Config) Parking(bundleID string) string {
+	if a, ok := c.Apps[bundleID]; ok && a.Parking != "" {
+		return a.Parking
+	}
+	return c.Layout.Parking
+}
+
+// Path returns the default config file location.
+func Path() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(home, ".config", "mosaico", "config.toml")
 }
 
 func Load(path string) (Config, error) {
@@ -38,7 +201,7 @@ func Load(path string) (Config, error) {
 		return Default(), nil
 	}
 
-	var config Config
+	config := Default()
 	_, err = toml.DecodeFile(path, &config)
 	if err != nil {
 		return Default(), err
@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"github.com/machina/mosaico/internal/actions"
+	"github.com/machina/mosaico/internal/strip"
+	"github.com/machina/mosaico/internal/wm"
+)
+
+// forgetWindow drops everything known about a window that is gone.
+func (d *Daemon) forgetWindow(id uint32) {
+	d.Displays.RemoveWindowByID(id)
+	d.Backend.Forget(id)
+	delete(d.parked, id)
+	delete(d.untiled, id)
+	delete(d.frames, id)
+	delete(d.floated, id)
+	delete(d.echoes, id)
+}
+
+// env is the actions.Env of a daemon.
+type env struct{ d *Daemon }
+
+func (e env) Displays() *strip.Displays { return e.d.Displays }
+func (e env) Backend() wm.Backend       { return e.d.Backend }
+func (e env) Forget(id uint32)          { e.d.forgetWindow(id) }
+
+func (e env) Logf(format string, args ...any) { e.d.logf(format, args...) }
+
+// Dispatch runs an action with the strips locked, then lays them out and
+// focuses the focused window.
+func (d *Daemon) Dispatch(c actions.Call) {
+	d.Locked(func() {
+		if err := actions.Run(env{d}, c); err != nil {
+			d.logf("ERROR %v\n", err)
+		}
+	})()
+}
@@ -0,0 +1,885 @@
+// Package daemon ties strips, layout and a window manager backend together.
+// It has no platform code, so it runs against the fake backend in tests.
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/machina/mosaico/internal/config"
+	"github.com/machina/mosaico/internal/layout"
+	"github.com/machina/mosaico/internal/rules"
+	"github.com/machina/mosaico/internal/strip"
+	"github.com/machina/mosaico/internal/wm"
+)
+
+const gap = float64(10)
+
+// maxLayoutPasses bounds how often a layout reruns after windows refused
+// their size and columns had to grow.
+const maxLayoutPasses = 3
+
+// focusEcho is how long after we focus a window a focus event for it is
+// taken as the echo of our own call.
+const focusEcho = 500 * time.Millisecond
+
+// dragSettle is how long a window must stop moving before a drag counts as
+// dropped.
+const dragSettle = 300 * time.Millisecond
+
+type Daemon struct {
+	Backend  wm.Backend
+	Config   config.Config
+	Displays *strip.Displays
+	// StatePath is where the state is saved after layouts, at most once
+	// every saveInterval, for the layout command. Empty disables saving.
+	StatePath string
+	Rules     *rules.Engine
+
+	// log is where progress and errors are printed.
+	log io.Writer
+
+	// parked remembers how each off-screen window was parked so it can be
+	// restored when it comes back into view.
+	parked map[uint32]layout.Parking
+	// sizes caches the minimum sizes apps refused to go below.
+	sizes *layout.Sizes
+	// untiled holds the windows a rule ignores or floats, as last seen, so
+	// they can be re-evaluated when their title changes.
+	untiled map[uint32]wm.WindowInfo
+	// echoes holds the windows we focused ourselves, until when focus
+	// events for them are echoes of our calls rather than the user's.
+	echoes map[uint32]time.Time
+	// frames holds the frame each visible window was last given, to tell
+	// the user's moves from ours.
+	frames map[uint32]layout.Rect
+	// drags holds a timer per window being dragged, fired once it settles.
+	drags map[uint32]*time.Timer
+	// floated holds windows dragged out of the strip, which rules must not
+	// put back.
+	floated map[uint32]bool
+	layouts layoutQueue
+	// displays is the display list as of the last sync.
+	displays []wm.Display
+	// columns is how many columns the focused strip had at the last
+	// layout.
+	columns atomic.Int64
+}
+
+// New returns a daemon for the backend that logs to log. Invalid rules
+// are reported and only the builtin ones are used.
+func New(backend wm.Backend, cfg config.Config, log io.Writer) *Daemon {
+	engine, err := rules.New(cfg.Rules)
+	if err != nil {
+		fmt.Fprintf(log, "WARNING: %v, ignoring [[rules]]\n", err)
+		engine, _ = rules.New(nil)
+	}
+	return &Daemon{
+		Backend:  backend,
+		Config:   cfg,
+		Displays: strip.NewDisplays(),
+		Rules:    engine,
+		log:      log,
+		parked:   make(map[uint32]layout.Parking),
+		sizes:    layout.NewSizes(),
+		untiled:  make(map[uint32]wm.WindowInfo),
+		frames:   make(map[uint32]layout.Rect),
+		drags:    make(map[uint32]*time.Timer),
+		floated:  make(map[uint32]bool),
+		echoes:   make(map[uint32]time.Time),
+	}
+}
+
+func (d *Daemon) logf(format string, args ...any) {
+	fmt.Fprintf(d.log, format, args...)
+}
+
+// Start fills the strips with the current windows. It does not lay them
+// out.
+func (d *Daemon) Start() {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+
+	displays := d.syncDisplays()
+	d.logf("Found %d displays\n", len(displays))
+
+	windows, _ := d.Backend.Windows()
+	d.logf("Found %d windows\n", len(windows))
+	for _, w := range windows {
+		if !w.Tileable() {
+			continue
+		}
+		d.logf("Window: ID=%d PID=%d App=%s Title=%s\n", w.ID, w.PID, w.OwnerName, w.Title)
+		d.addWindow(displays, w)
+	}
+
+	for _, s := range d.Displays.Strips {
+		d.logf("Display %d strip has %d columns\n", s.DisplayID, len(s.Columns))
+	}
+}
+
+// syncDisplays refreshes the display list and returns it. Must be called
+// with Displays.Mutex held.
+func (d *Daemon) syncDisplays() []wm.Display {
+	displays, err := d.Backend.Displays()
+	if err != nil {
+		d.logf("ERROR Displays: %v\n", err)
+		return d.displays // the last known ones
+	}
+
+	ids := make([]uint32, len(displays))
+	for i, disp := range displays {
+		ids[i] = disp.ID
+	}
+	d.Displays.Sync(ids, func(id uint32) *strip.Strip {
+		s := strip.New()
+		for _, disp := range displays {
+			if disp.ID == id {
+				s.VisibleCount = d.Config.VisibleCount(disp.ID, disp.Main)
+			}
+		}
+		return s
+	})
+	for _, disp := range displays {
+		if s := d.Displays.ForDisplay(disp.ID); s != nil {
+			s.SetViewportWidth(d.screenRect(disp).Width)
+		}
+	}
+	d.displays = displays
+	return displays
+}
+
+// currentDisplays returns the displays as of the last sync, syncing if
+// there was none. Must be called with Displays.Mutex held.
+func (d *Daemon) currentDisplays() []wm.Display {
+	if d.displays == nil {
+		return d.syncDisplays()
+	}
+	return d.displays
+}
+
+// SyncDisplays picks up displays being plugged, unplugged or changing
+// resolution, and the Dock moving, and relayouts if anything changed.
+// Columns of an unplugged display move to another one until it returns.
+func (d *Daemon) SyncDisplays() {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+
+	prev := d.displays
+	if displays := d.syncDisplays(); !slices.Equal(prev, displays) {
+		d.logf("Displays changed: %d -> %d\n", len(prev), len(displays))
+		d.scheduleLayout(false)
+	}
+}
+
+// displayRect is the whole display, which windows are parked outside of.
+func displayRect(disp wm.Display) layout.Rect {
+	return layout.Rect{X: disp.X, Y: disp.Y, Width: disp.Width, Height: disp.Height}
+}
+
+// screenRect is the part of a display windows are laid out on: by default
+// the area the menu bar and Dock leave, minus the configured padding.
+func (d *Daemon) screenRect(disp wm.Display) layout.Rect {
+	r := displayRect(disp)
+	if d.Config.Layout.Area != "full" {
+		f := disp.Usable()
+		r = layout.Rect{X: f.X, Y: f.Y, Width: f.Width, Height: f.Height}
+	}
+	pad := d.Config.Layout.Padding
+	return layout.Rect{
+		X:      r.X + pad.Left,
+		Y:      r.Y + pad.Top,
+		Width:  max(r.Width-pad.Left-pad.Right, 1),
+		Height: max(r.Height-pad.Top-pad.Bottom, 1),
+	}
+}
+
+func (d *Daemon) parkingFor(p layout.Placement) layout.Parking {
+	strategy, err := layout.ParseParking(d.Config.Parking(p.Window.BundleID))
+	if err != nil {
+		return layout.ParkHide
+	}
+	return strategy
+}
+
+// parkPosition returns the edge position for a parked window, switching to
+// the other edge when another display sits on that side.
+func parkPosition(p layout.Placement, screen layout.Rect, displays []wm.Display) (float64, float64) {
+	x, y := layout.EdgePosition(p, screen)
+	for _, disp := range displays {
+		if disp.Contains(x+p.Frame.Width/2, y) && displayRect(disp) != screen {
+			p.Side = -p.Side
+			if p.Side == 0 {
+				p.Side = -1
+			}
+			return layout.EdgePosition(p, screen)
+		}
+	}
+	return x, y
+}
+
+// ApplyLayout moves every window into place.
+func (d *Daemon) ApplyLayout() {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+	d.applyLayout(false)
+}
+
+// applyLayout lays out the current state, then focuses the focused window
+// if focus is set. It stops early when a newer strip change cut it short;
+// that change schedules its own layout.
+func (d *Daemon) applyLayout(focus bool) {
+	gen := d.layouts.gen.Load()
+	displays := d.currentDisplays()
+	var plan layout.ParkingPlan
+	for range maxLayoutPasses {
+		var widened, done bool
+		plan, widened, done = d.layoutPass(displays, gen)
+		if !done {
+			return
+		}
+		if !widened {
+			break
+		}
+	}
+
+	for _, pid := range plan.UnhideApps {
+		d.Backend.UnhideApp(pid)
+	}
+	// Before hiding: hiding the frontmost app hands focus to whatever is
+	// behind it
+	if focus {
+		d.focusCurrentWindow()
+	}
+	for _, pid := range plan.HideApps {
+		d.Backend.HideApp(pid)
+	}
+
+	if s := d.Displays.FocusedStrip(); s != nil {
+		d.columns.Store(int64(len(s.Columns)))
+	}
+	// Saved for `layout`, which prints what we meant to do
+	d.scheduleSave()
+}
+
+// ColumnCount returns how many columns the focused strip had at the last
+// layout. It doesn't wait for the displays lock, so the event tap can call
+// it.
+func (d *Daemon) ColumnCount() int {
+	return int(d.columns.Load())
+}
+
+// layoutPass moves every window into place and returns the apps to hide
+// and unhide. It reports whether a column had to grow to fit a window, in
+// which case the pass should be rerun, and whether it finished before being
+// superseded.
+func (d *Daemon) layoutPass(displays []wm.Display, gen uint64) (plan layout.ParkingPlan, widened, done bool) {
+	var placements []layout.Placement
+	screens := make(map[uint32]layout.Rect)
+	strips := make(map[uint32]*strip.Strip)
+	for _, disp := range displays {
+		s := d.Displays.ForDisplay(disp.ID)
+		if s == nil {
+			continue
+		}
+		if layout.ApplyMinimums(s, d.sizes, gap) {
+			s.ScrollToColumn(s.FocusedCol)
+		}
+		for _, p := range layout.Compute(s, d.screenRect(disp), gap) {
+			strips[p.Window.ID] = s
+			placements = append(placements, p)
+			screens[p.Window.ID] = displayRect(disp)
+		}
+	}
+
+	plan = layout.ResolveParking(placements, d.parkingFor)
+	for _, p := range placements {
+		if d.layouts.superseded(gen) {
+			return plan, widened, false
+		}
+		win := p.Window
+		if p.Visible {
+			if d.drags[win.ID] != nil {
+				continue // don't pull it from under the mouse
+			}
+			if d.parked[win.ID] == layout.ParkMinimize {
+				d.Backend.SetMinimized(win.PID, win.ID, false)
+			}
+			delete(d.parked, win.ID)
+
+			f := p.Frame
+			actual, err := d.Backend.SetFrame(win.PID, win.ID, wm.Frame{X: f.X, Y: f.Y, Width: f.Width, Height: f.Height})
+			if err != nil {
+				d.logFailure("SetFrame", win, err)
+				continue
+			}
+			d.logf("Positioned %s at x=%.0f\n", win.Label(), f.X)
+			d.frames[win.ID] = layout.Rect{X: actual.X, Y: actual.Y, Width: actual.Width, Height: actual.Height}
+			if d.reconcileFrame(p, strips[win.ID], actual) {
+				widened = true
+			}
+			continue
+		}
+
+		delete(d.frames, win.ID)
+		strategy, ok := plan.Windows[win.ID]
+		if !ok {
+			continue // the whole app is hidden
+		}
+		if d.parked[win.ID] == layout.ParkMinimize && strategy != layout.ParkMinimize {
+			d.Backend.SetMinimized(win.PID, win.ID, false)
+		}
+
+		switch strategy {
+		case layout.ParkEdge:
+			x, y := parkPosition(p, screens[win.ID], displays)
+			if err := d.Backend.SetPosition(win.PID, win.ID, x, y); err != nil {
+				d.logFailure("SetPosition", win, err)
+			}
+		case layout.ParkMinimize:
+			if d.parked[win.ID] != layout.ParkMinimize {
+				if err := d.Backend.SetMinimized(win.PID, win.ID, true); err != nil {
+					d.logFailure("SetMinimized", win, err)
+				}
+			}
+		case layout.ParkHide:
+			// resolved to an app hide above
+		}
+		d.parked[win.ID] = strategy
+	}
+	return plan, widened, true
+}
+
+// logFailure logs a window operation that failed, unless the window was
+// only skipped while its app is unresponsive, which the backend reports.
+func (d *Daemon) logFailure(op string, win *strip.Window, err error) {
+	if !errors.Is(err, wm.ErrBackingOff) {
+		d.logf("ERROR %s %s: %v\n", op, win.Label(), err)
+	}
+}
+
+// reconcileFrame adapts the layout to a window that didn't take the frame
+// it was given. It reports whether the window's column was widened.
+func (d *Daemon) reconcileFrame(p layout.Placement, s *strip.Strip, actual wm.Frame) bool {
+	win := p.Window
+	got := layout.Rect{X: actual.X, Y: actual.Y, Width: actual.Width, Height: actual.Height}
+	fix := layout.Reconcile(p, got, gap)
+	if fix.Kind == layout.FixNone {
+		return false
+	}
+
+	d.logf("Frame mismatch %s: wanted %.0fx%.0f at %.0f,%.0f, got %.0fx%.0f at %.0f,%.0f\n",
+		win.Label(), p.Frame.Width, p.Frame.Height, p.Frame.X, p.Frame.Y,
+		got.Width, got.Height, got.X, got.Y)
+	if d.sizes.Learn(win.BundleID, p.Frame, got) {
+		d.logf("Learned minimum size for %s: %+v\n", win.BundleID, d.sizes.Min(win.BundleID))
+	}
+
+	switch fix.Kind {
+	case layout.FixWiden:
+		s.Columns[p.Column].Fit = fix.ColumnWidth
+		s.ScrollToColumn(s.FocusedCol)
+		return true
+	case layout.FixRecenter:
+		if err := d.Backend.SetPosition(win.PID, win.ID, fix.X, fix.Y); err != nil {
+			d.logFailure("SetPosition", win, err)
+		}
+		d.frames[win.ID] = layout.Rect{X: fix.X, Y: fix.Y, Width: got.Width, Height: got.Height}
+	case layout.FixNone:
+	}
+	return false
+}
+
+func (d *Daemon) state(displays []wm.Display) layout.State {
+	st := layout.State{Displays: d.Displays, Gap: gap, Failures: d.Backend.Failures()}
+	for _, disp := range displays {
+		st.Screens = append(st.Screens, layout.Screen{DisplayID: disp.ID, Frame: d.screenRect(disp)})
+	}
+	return st
+}
+
+// DryRun prints the layout for the current windows without moving them.
+func (d *Daemon) DryRun(w io.Writer, asJSON bool) error {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+
+	entries := layout.DryRun(d.state(d.syncDisplays()), d.sizes, d.parkingFor)
+	if asJSON {
+		return layout.WriteJSON(w, entries)
+	}
+	return layout.WriteTable(w, entries)
+}
+
+func (d *Daemon) focusCurrentWindow() {
+	win := d.Displays.FocusedWindow()
+	if win == nil {
+		return
+	}
+	d.echoes[win.ID] = time.Now().Add(focusEcho)
+	if err := d.Backend.Focus(win.PID, win.ID); err != nil {
+		d.logf("ERROR Focus %s: %v\n", win.Label(), err)
+	}
+}
+
+// followFocus moves the strip focus to a window focused outside mosaico,
+// by a click or Cmd-Tab, and scrolls it into view.
+func (d *Daemon) followFocus(e wm.Event) {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+
+	id := e.ID
+	if id == 0 {
+		id = d.appWindow(e.PID)
+	}
+	if until, ok := d.echoes[id]; ok {
+		delete(d.echoes, id)
+		if time.Now().Before(until) {
+			return
+		}
+	}
+	if win := d.Displays.FocusedWindow(); id == 0 || (win != nil && win.ID == id) {
+		return
+	}
+	if !d.Displays.FocusWindow(id) {
+		return
+	}
+	d.logf("Following focus to %s\n", d.Displays.FocusedWindow().Label())
+	d.scheduleLayout(false)
+}
+
+// appWindow returns a tiled window of the app, preferring one we just
+// focused, then the focused display, or 0 if it has none.
+func (d *Daemon) appWindow(pid uint32) uint32 {
+	for id, until := range d.echoes {
+		if win := d.Displays.Window(id); win != nil && win.PID == pid && time.Now().Before(until) {
+			return id
+		}
+	}
+	strips := d.Displays.Strips
+	if s := d.Displays.FocusedStrip(); s != nil {
+		strips = append([]*strip.Strip{s}, strips...)
+	}
+	for _, s := range strips {
+		for _, col := range s.Columns {
+			for _, win := range col.Windows {
+				if win.PID == pid {
+					return win.ID
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// addWindow evaluates the rules for a new window and places it on the
+// strip of the display it opened on, unless a rule says otherwise. It
+// reports whether the window was tiled.
+func (d *Daemon) addWindow(displays []wm.Display, w wm.WindowInfo) bool {
+	rule := d.Rules.Match(w)
+	if !rule.Tiled() {
+		d.logf("Not tiling %s (ID=%d): %s -> %s\n", w.DisplayName(), w.ID, rule, rule.Actions())
+		d.untiled[w.ID] = w
+		return false
+	}
+	delete(d.untiled, w.ID)
+
+	disp := wm.DisplayForPoint(displays, w.X+w.Width/2, w.Y+w.Height/2)
+	if rule != nil && rule.OpenOnDisplay != "" {
+		if target, ok := findDisplay(displays, rule.OpenOnDisplay); ok {
+			disp = target
+		} else {
+			d.logf("WARNING: %s: no display %q\n", rule, rule.OpenOnDisplay)
+		}
+	}
+	s := d.Displays.ForDisplay(disp.ID)
+	if s == nil {
+		s = d.Displays.FocusedStrip()
+	}
+	if s == nil {
+		return false
+	}
+
+	win := &strip.Window{
+		ID: w.ID, PID: w.PID, BundleID: w.BundleID, AppName: w.OwnerName, Title: w.Title,
+	}
+	if rule == nil {
+		s.AddWindow(win)
+		return true
+	}
+	d.logf("Rule for %s (ID=%d): %s -> %s\n", w.DisplayName(), w.ID, rule, rule.Actions())
+
+	if rule.StackWithApp {
+		if col := s.AppColumn(w.BundleID); col != nil {
+			col.Windows = append(col.Windows, win)
+			return true
+		}
+	}
+	col := &strip.Column{Windows: []*strip.Window{win}}
+	if rule.ColumnWidth > 0 {
+		col.Width = rule.ColumnWidth
+		if col.Width <= 1 {
+			col.Width *= d.screenRect(disp).Width
+		}
+	}
+	s.PlaceColumn(col, rule.OpenAtColumn)
+	return true
+}
+
+// findDisplay resolves a display name from the config: a display ID or
+// "main".
+func findDisplay(displays []wm.Display, name string) (wm.Display, bool) {
+	for _, disp := range displays {
+		if (name == "main" && disp.Main) || name == strconv.FormatUint(uint64(disp.ID), 10) {
+			return disp, true
+		}
+	}
+	return wm.Display{}, false
+}
+
+// Explain describes which rule applies to each on-screen window, or only
+// to the window with the given ID when id is not 0.
+func (d *Daemon) Explain(w io.Writer, id uint32) error {
+	windows, err := d.Backend.Windows()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, win := range windows {
+		if (id != 0 && win.ID != id) || (id == 0 && win.Layer != 0) {
+			continue
+		}
+		found = true
+		fmt.Fprintf(w, "%s (ID=%d app=%s bundle=%s role=%s subrole=%s size=%.0fx%.0f)\n",
+			win.DisplayName(), win.ID, win.OwnerName, win.BundleID, win.Role, win.Subrole, win.Width, win.Height)
+		if !win.Tileable() {
+			fmt.Fprintln(w, "  not tileable (dialog, panel, minimized or fullscreen)")
+		}
+		for _, line := range d.Rules.Explain(win) {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	if id != 0 && !found {
+		return fmt.Errorf("no on-screen window %d", id)
+	}
+	return nil
+}
+
+// Reconcile picks up windows that opened or closed since the last call and
+// relayouts if anything changed.
+func (d *Daemon) Reconcile() {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+
+	windows, _ := d.Backend.Windows()
+	known := d.knownWindows()
+	changed := d.updateWindows(d.currentDisplays(), windows, known)
+
+	// Remove closed windows. Parked windows are off screen, so check
+	// against every window rather than the on-screen list.
+	if existing, err := d.Backend.WindowIDs(); err == nil {
+		for id := range known {
+			if !existing[id] {
+				d.forgetWindow(id)
+				changed = true
+				d.logf("Removed window ID=%d\n", id)
+			}
+		}
+	}
+
+	if changed {
+		d.scheduleLayout(false)
+	}
+}
+
+// knownWindows returns the IDs of the tiled and untiled windows.
+func (d *Daemon) knownWindows() map[uint32]bool {
+	known := d.Displays.GetAllWindowIDs()
+	for id := range d.untiled {
+		known[id] = true
+	}
+	return known
+}
+
+// updateWindows adds the new windows among windows and picks up title
+// changes of the known ones. It reports whether any strip changed.
+func (d *Daemon) updateWindows(displays []wm.Display, windows []wm.WindowInfo, known map[uint32]bool) bool {
+	changed := false
+	for _, w := range windows {
+		if !known[w.ID] {
+			if !w.Tileable() {
+				continue
+			}
+			if d.addWindow(displays, w) {
+				changed = true
+				d.logf("New window: %s (ID=%d)\n", w.DisplayName(), w.ID)
+			}
+			continue
+		}
+		if d.titleChanged(displays, w) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// titleChanged updates a known window's title and re-evaluates the rules,
+// since title rules may now match differently. It reports whether the
+// window moved in or out of a strip.
+func (d *Daemon) titleChanged(displays []wm.Display, w wm.WindowInfo) bool {
+	if prev, ok := d.untiled[w.ID]; ok {
+		if w.Title == "" || prev.Title == w.Title || d.floated[w.ID] {
+			return false
+		}
+		d.logf("Title changed: %q -> %q (ID=%d)\n", prev.Title, w.Title, w.ID)
+		return d.addWindow(displays, w)
+	}
+
+	win := d.Displays.Window(w.ID)
+	if win == nil || w.Title == "" || win.Title == w.Title {
+		return false
+	}
+	d.logf("Title changed: %q -> %q (ID=%d)\n", win.Title, w.Title, w.ID)
+	win.Title = w.Title
+	if rule := d.Rules.Match(w); !rule.Tiled() {
+		d.logf("Untiling %s (ID=%d): %s -> %s\n", w.DisplayName(), w.ID, rule, rule.Actions())
+		d.Displays.RemoveWindowByID(w.ID)
+		if d.parked[w.ID] == layout.ParkMinimize {
+			d.Backend.SetMinimized(w.PID, w.ID, false)
+		}
+		delete(d.parked, w.ID)
+		d.untiled[w.ID] = w
+		return true
+	}
+	return false
+}
+
+// Run reacts to window events as they arrive, and reconciles every
+// interval to catch anything the events missed. It returns when the event
+// source closes.
+func (d *Daemon) Run(src wm.EventSource, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-src.Events():
+			if !ok {
+				return
+			}
+			d.HandleEvent(e)
+		case <-ticker.C:
+			d.SyncDisplays()
+			d.Reconcile()
+		}
+	}
+}
+
+// HandleEvent applies one window event.
+func (d *Daemon) HandleEvent(e wm.Event) {
+	switch e.Kind {
+	case wm.EventCreated, wm.EventTitleChanged:
+		d.windowChanged(e)
+	case wm.EventDestroyed:
+		d.windowDestroyed(e)
+	case wm.EventDisplaysChanged:
+		d.SyncDisplays()
+	case wm.EventFocused:
+		d.followFocus(e)
+	case wm.EventMoved, wm.EventResized:
+		d.windowMoved(e)
+	}
+}
+
+// windowChanged adds a window that opened, or picks up its new title. For
+// app-level events it looks at every window of the app.
+func (d *Daemon) windowChanged(e wm.Event) {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+
+	windows, err := d.Backend.AppWindows(e.PID)
+	if err != nil {
+		return
+	}
+	if e.ID != 0 {
+		windows = slices.DeleteFunc(windows, func(w wm.WindowInfo) bool { return w.ID != e.ID })
+	}
+	if d.updateWindows(d.currentDisplays(), windows, d.knownWindows()) {
+		d.scheduleLayout(false)
+	}
+}
+
+// windowDestroyed forgets a window that closed, or every window of an app
+// that quit.
+func (d *Daemon) windowDestroyed(e wm.Event) {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+
+	var gone []uint32
+	if e.ID != 0 {
+		if d.knownWindows()[e.ID] {
+			gone = append(gone, e.ID)
+		}
+	} else {
+		for _, s := range d.Displays.Strips {
+			for _, col := range s.Columns {
+				for _, w := range col.Windows {
+					if w.PID == e.PID {
+						gone = append(gone, w.ID)
+					}
+				}
+			}
+		}
+		for id, w := range d.untiled {
+			if w.PID == e.PID {
+				gone = append(gone, id)
+			}
+		}
+	}
+	for _, id := range gone {
+		d.forgetWindow(id)
+		d.logf("Removed window ID=%d\n", id)
+	}
+	if len(gone) > 0 {
+		d.scheduleLayout(false)
+	}
+}
+
+// windowMoved notices the user moving or resizing a tiled window. Our own
+// layout moves windows too, so only frames that differ from the one we
+// gave count. The drop is handled once the window stops moving.
+func (d *Daemon) windowMoved(e wm.Event) {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+
+	expected, ok := d.frames[e.ID]
+	if !ok {
+		return // not tiled, or parked
+	}
+	f, err := d.Backend.Frame(e.PID, e.ID)
+	if err != nil {
+		return
+	}
+	actual := layout.Rect{X: f.X, Y: f.Y, Width: f.Width, Height: f.Height}
+	if !layout.Moved(expected, actual) && !layout.Resized(expected, actual) {
+		return
+	}
+
+	if t := d.drags[e.ID]; t != nil {
+		t.Reset(dragSettle)
+		return
+	}
+	d.drags[e.ID] = time.AfterFunc(dragSettle, func() { d.dropWindow(e.PID, e.ID) })
+}
+
+// dropWindow applies the drag policy to a window the user stopped moving.
+// A resize sets the width of the window's column instead.
+func (d *Daemon) dropWindow(pid, id uint32) {
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+
+	delete(d.drags, id)
+	expected, ok := d.frames[id]
+	s := d.Displays.FindWindow(id)
+	if !ok || s == nil {
+		return
+	}
+	f, err := d.Backend.Frame(pid, id)
+	if err != nil {
+		return
+	}
+	actual := layout.Rect{X: f.X, Y: f.Y, Width: f.Width, Height: f.Height}
+
+	switch {
+	case layout.Resized(expected, actual):
+		for _, col := range s.Columns {
+			if slices.ContainsFunc(col.Windows, func(w *strip.Window) bool { return w.ID == id }) {
+				col.Width, col.Fit = actual.Width+gap, 0
+			}
+		}
+		s.ScrollToColumn(s.FocusedCol)
+		d.logf("Resized window ID=%d to width %.0f\n", id, actual.Width)
+	case layout.Moved(expected, actual):
+		d.dragged(s, id, actual)
+	}
+	d.scheduleLayout(false)
+}
+
+// dragged applies the drag policy to a window moved out of place.
+func (d *Daemon) dragged(src *strip.Strip, id uint32, frame layout.Rect) {
+	policy, err := layout.ParseDragPolicy(d.Config.Layout.Drag)
+	if err != nil {
+		policy = layout.DragSnap
+	}
+	win := d.Displays.Window(id)
+
+	switch policy {
+	case layout.DragSnap:
+		d.logf("Snapping back %s\n", win.Label())
+	case layout.DragFloat:
+		d.logf("Floating %s\n", win.Label())
+		src.RemoveWindowByID(id)
+		delete(d.frames, id)
+		d.floated[id] = true
+		d.untiled[id] = wm.WindowInfo{
+			ID: win.ID, PID: win.PID, BundleID: win.BundleID, OwnerName: win.AppName, Title: win.Title,
+			X: frame.X, Y: frame.Y, Width: frame.Width, Height: frame.Height,
+		}
+	case layout.DragReorder:
+		disp := wm.DisplayForPoint(d.currentDisplays(), frame.X+frame.Width/2, frame.Y+frame.Height/2)
+		dst := d.Displays.ForDisplay(disp.ID)
+		if dst == nil {
+			return
+		}
+		drop, ok := layout.DropTarget(dst, d.screenRect(disp), id, frame.X+frame.Width/2, frame.Y+frame.Height/2)
+		if !ok {
+			return
+		}
+
+		// The window's own column keeps its width if it moves whole, and
+		// its row shifts when restacked lower in the same column
+		var width float64
+		for _, col := range src.Columns {
+			if row := slices.IndexFunc(col.Windows, func(w *strip.Window) bool { return w.ID == id }); row >= 0 {
+				if len(col.Windows) == 1 {
+					width = col.Width
+				}
+				if col == drop.Stack && drop.Row > row {
+					drop.Row--
+				}
+			}
+		}
+		src.RemoveWindowByID(id)
+		col := dst.InsertWindow(win, drop.Before, drop.Stack, drop.Row)
+		if drop.Stack == nil {
+			col.Width = width
+		}
+		d.Displays.FocusWindow(id)
+		d.logf("Moved %s to column %d on display %d\n", win.Label(), dst.FocusedCol+1, dst.DisplayID)
+	}
+}
+
+// Watch syncs displays and windows every interval. It never returns.
+func (d *Daemon) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		d.SyncDisplays()
+		d.Reconcile()
+	}
+}
+
+// Locked wraps a strip operation in the displays lock. The strip changes
+// right away; the layout and focus follow once a burst of operations
+// settles.
+func (d *Daemon) Locked(f func()) func() {
+	return func() {
+		d.layouts.supersede()
+		d.Displays.Mutex.Lock()
+		defer d.Displays.Mutex.Unlock()
+		f()
+		d.scheduleLayout(true)
+	}
+}
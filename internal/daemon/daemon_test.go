@@ -0,0 +1,638 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/machina/mosaico/internal/actions"
+	"github.com/machina/mosaico/internal/config"
+	"github.com/machina/mosaico/internal/strip"
+	"github.com/machina/mosaico/internal/wm"
+	"github.com/machina/mosaico/internal/wm/fake"
+)
+
+// window is an 800x600 window of app pid, opened at x.
+func window(id, pid uint32, x float64) fake.Window {
+	return fake.Window{WindowInfo: wm.WindowInfo{
+		ID: id, PID: pid, BundleID: fmt.Sprintf("com.example.app%d", pid), OwnerName: fmt.Sprintf("App%d", pid),
+		X: x, Width: 800, Height: 600, Standard: true,
+	}}
+}
+
+func call(name string, count int) actions.Call {
+	return actions.Call{Action: actions.Lookup(name), Count: count}
+}
+
+// changes lists the calls that change windows, in a compact form.
+func changes(calls []fake.Call) []string {
+	var out []string
+	for _, c := range calls {
+		switch c.Method {
+		case "SetFrame":
+			out = append(out, fmt.Sprintf("SetFrame %d %.0f,%.0f %.0fx%.0f", c.ID, c.Frame.X, c.Frame.Y, c.Frame.Width, c.Frame.Height))
+		case "SetPosition":
+			out = append(out, fmt.Sprintf("SetPosition %d %.0f,%.0f", c.ID, c.Frame.X, c.Frame.Y))
+		case "SetMinimized":
+			out = append(out, fmt.Sprintf("SetMinimized %d %v", c.ID, c.Bool))
+		case "HideApp", "UnhideApp", "Quit":
+			out = append(out, fmt.Sprintf("%s %d", c.Method, c.PID))
+		case "Focus", "Close", "Forget":
+			out = append(out, fmt.Sprintf("%s %d", c.Method, c.ID))
+		}
+	}
+	return out
+}
+
+// run delivers events through an event source, as the window system would.
+func run(d *Daemon, events ...wm.Event) {
+	src := fake.NewEventSource()
+	src.Send(events...)
+	src.Close()
+	d.Run(src, time.Hour)
+}
+
+// withDock has a 25 pixel menu bar and an 80 pixel Dock.
+var withDock = wm.Display{ID: 1, Width: 2560, Height: 1440, Main: true,
+	Visible: wm.Frame{Y: 25, Width: 2560, Height: 1335}}
+
+var twoDisplays = []wm.Display{
+	{ID: 1, Width: 2560, Height: 1440, Main: true},
+	{ID: 2, X: 2560, Width: 1920, Height: 1080},
+}
+
+func TestDaemon(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   func(cfg *config.Config)
+		displays []wm.Display
+		windows  []fake.Window
+		do       func(d *Daemon, b *fake.Backend)
+		want     []string
+	}{
+		{
+			name:    "window opens",
+			windows: []fake.Window{window(1, 10, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.AddWindow(window(2, 20, 0))
+				d.Reconcile()
+			},
+			want: []string{
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name:    "window closes",
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.RemoveWindow(1)
+				d.Reconcile()
+			},
+			want: []string{
+				"Forget 1",
+				"SetFrame 2 5,5 1270x1430",
+				"SetFrame 3 1285,5 1270x1430",
+				"UnhideApp 20",
+				"UnhideApp 30",
+			},
+		},
+		{
+			name:    "opened event adds only its window",
+			windows: []fake.Window{window(1, 10, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.AddWindow(window(2, 20, 0))
+				b.AddWindow(window(3, 20, 0))
+				run(d, wm.Event{Kind: wm.EventCreated, PID: 20, ID: 2})
+			},
+			want: []string{
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name:    "app launch adds its windows",
+			windows: []fake.Window{window(1, 10, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.AddWindow(window(2, 20, 0))
+				b.AddWindow(window(3, 30, 0))
+				run(d, wm.Event{Kind: wm.EventCreated, PID: 20})
+			},
+			want: []string{
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name:    "destroyed event removes the window",
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.RemoveWindow(1)
+				run(d, wm.Event{Kind: wm.EventDestroyed, PID: 10, ID: 1})
+			},
+			want: []string{
+				"Forget 1",
+				"SetFrame 2 5,5 1270x1430",
+				"SetFrame 3 1285,5 1270x1430",
+				"UnhideApp 20",
+				"UnhideApp 30",
+			},
+		},
+		{
+			name:    "closed window leaves the strip at once",
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, _ *fake.Backend) {
+				d.Dispatch(call("close-window", 0))
+				d.Settle()
+				run(d, wm.Event{Kind: wm.EventDestroyed, PID: 10, ID: 1})
+			},
+			want: []string{
+				"Close 1",
+				"Forget 1",
+				"SetFrame 2 5,5 1270x1430",
+				"SetFrame 3 1285,5 1270x1430",
+				"UnhideApp 20",
+				"UnhideApp 30",
+				"Focus 2",
+			},
+		},
+		{
+			name:    "window kept open comes back with the next reconcile",
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				d.Dispatch(call("close-window", 0))
+				d.Settle()
+				b.AddWindow(window(1, 10, 0))
+				b.ResetCalls()
+				d.Reconcile()
+			},
+			want: []string{
+				"SetFrame 2 5,5 1270x1430",
+				"SetFrame 1 1285,5 1270x1430",
+				"UnhideApp 20",
+				"UnhideApp 10",
+			},
+		},
+		{
+			name:    "app quit removes its windows",
+			windows: []fake.Window{window(1, 10, 0), window(2, 10, 0), window(3, 30, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.RemoveWindow(1)
+				b.RemoveWindow(2)
+				run(d, wm.Event{Kind: wm.EventDestroyed, PID: 10})
+			},
+			want: []string{
+				"Forget 1",
+				"Forget 2",
+				"SetFrame 3 5,5 1270x1430",
+				"UnhideApp 30",
+			},
+		},
+		{
+			name:     "layout uses the usable area",
+			displays: []wm.Display{withDock},
+			windows:  []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do:       func(d *Daemon, _ *fake.Backend) { d.ApplyLayout() },
+			want: []string{
+				"SetFrame 1 5,30 1270x1325",
+				"SetFrame 2 1285,30 1270x1325",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name: "padding is kept free of the usable area",
+			config: func(cfg *config.Config) {
+				cfg.Layout.Padding = config.Padding{Top: 40, Bottom: 20, Left: 100, Right: 100}
+			},
+			displays: []wm.Display{withDock},
+			windows:  []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do:       func(d *Daemon, _ *fake.Backend) { d.ApplyLayout() },
+			want: []string{
+				"SetFrame 1 105,70 1170x1265",
+				"SetFrame 2 1285,70 1170x1265",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name:     "full area covers the menu bar and Dock",
+			config:   func(cfg *config.Config) { cfg.Layout.Area = "full" },
+			displays: []wm.Display{withDock},
+			windows:  []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do:       func(d *Daemon, _ *fake.Backend) { d.ApplyLayout() },
+			want: []string{
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name:     "Dock moving to the left relays out",
+			displays: []wm.Display{withDock},
+			windows:  []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				moved := withDock
+				moved.Visible = wm.Frame{X: 80, Y: 25, Width: 2480, Height: 1415}
+				b.SetDisplays(moved)
+				run(d, wm.Event{Kind: wm.EventDisplaysChanged})
+			},
+			want: []string{
+				"SetFrame 1 85,30 1230x1405",
+				"SetFrame 2 1325,30 1230x1405",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name: "full area with padding",
+			config: func(cfg *config.Config) {
+				cfg.Layout.Area = "full"
+				cfg.Layout.Padding = config.Padding{Top: 40, Bottom: 20, Left: 100, Right: 100}
+			},
+			displays: []wm.Display{withDock},
+			windows:  []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do:       func(d *Daemon, _ *fake.Backend) { d.ApplyLayout() },
+			want: []string{
+				"SetFrame 1 105,45 1170x1370",
+				"SetFrame 2 1285,45 1170x1370",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name: "fractional column width is of the padded screen",
+			config: func(cfg *config.Config) {
+				cfg.Layout.Padding = config.Padding{Left: 280, Right: 280}
+				cfg.Rules = []config.RuleConfig{{BundleID: "com.example.app20", ColumnWidth: 0.25}}
+			},
+			windows: []fake.Window{window(1, 10, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.AddWindow(window(2, 20, 0))
+				d.Reconcile()
+			},
+			want: []string{
+				"SetFrame 1 285,5 990x1430",
+				"SetFrame 2 1285,5 490x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name: "title change untiles the window",
+			config: func(cfg *config.Config) {
+				cfg.Rules = []config.RuleConfig{{Title: "^Preferences$", Float: true}}
+			},
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				w := window(1, 10, 0)
+				w.Title = "Preferences"
+				b.RemoveWindow(1)
+				b.AddWindow(w)
+				run(d, wm.Event{Kind: wm.EventTitleChanged, PID: 10, ID: 1})
+			},
+			want: []string{
+				"SetFrame 2 5,5 1270x1430",
+				"SetFrame 3 1285,5 1270x1430",
+				"UnhideApp 20",
+				"UnhideApp 30",
+			},
+		},
+		{
+			name:    "dragged window snaps back",
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.MoveWindow(2, wm.Frame{X: 300, Y: 200, Width: 1270, Height: 1430})
+				d.dropWindow(20, 2)
+			},
+			want: []string{
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name:    "dragged window floats",
+			config:  func(cfg *config.Config) { cfg.Layout.Drag = "float" },
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.MoveWindow(1, wm.Frame{X: 300, Y: 200, Width: 1270, Height: 1430})
+				d.dropWindow(10, 1)
+			},
+			want: []string{
+				"SetFrame 2 5,5 1270x1430",
+				"SetFrame 3 1285,5 1270x1430",
+				"UnhideApp 20",
+				"UnhideApp 30",
+			},
+		},
+		{
+			name:    "window dragged before the first column",
+			config:  func(cfg *config.Config) { cfg.Layout.Drag = "reorder" },
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.MoveWindow(2, wm.Frame{X: -1000, Y: 5, Width: 1270, Height: 1430})
+				d.dropWindow(20, 2)
+			},
+			want: []string{
+				"SetFrame 2 5,5 1270x1430",
+				"SetFrame 1 1285,5 1270x1430",
+				"UnhideApp 20",
+				"UnhideApp 10",
+			},
+		},
+		{
+			name:    "first column dragged before itself stays first",
+			config:  func(cfg *config.Config) { cfg.Layout.Drag = "reorder" },
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.MoveWindow(1, wm.Frame{X: -1000, Y: 5, Width: 1270, Height: 1430})
+				d.dropWindow(10, 1)
+			},
+			want: []string{
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name:    "window dragged onto the middle of a column stacks",
+			config:  func(cfg *config.Config) { cfg.Layout.Drag = "reorder" },
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.MoveWindow(2, wm.Frame{X: 5, Y: 400, Width: 1270, Height: 1430})
+				d.dropWindow(20, 2)
+			},
+			want: []string{
+				"SetFrame 1 5,5 1270x710",
+				"SetFrame 2 5,725 1270x710",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+		{
+			name:    "scroll hides the app left behind",
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, _ *fake.Backend) {
+				d.Dispatch(call("scroll-right", 2))
+			},
+			want: []string{
+				"SetFrame 2 5,5 1270x1430",
+				"SetFrame 3 1285,5 1270x1430",
+				"UnhideApp 20",
+				"UnhideApp 30",
+				"Focus 3",
+				"HideApp 10",
+			},
+		},
+		{
+			name:    "scroll parks at the edge",
+			config:  func(cfg *config.Config) { cfg.Layout.Parking = "edge" },
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, _ *fake.Backend) {
+				d.Dispatch(call("scroll-right", 2))
+			},
+			want: []string{
+				"SetPosition 1 -1269,5",
+				"SetFrame 2 5,5 1270x1430",
+				"SetFrame 3 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+				"UnhideApp 30",
+				"Focus 3",
+			},
+		},
+		{
+			name:    "scroll minimizes and restores",
+			config:  func(cfg *config.Config) { cfg.Layout.Parking = "minimize" },
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, _ *fake.Backend) {
+				d.Dispatch(call("scroll-right", 2))
+			},
+			want: []string{
+				"SetMinimized 1 true",
+				"SetFrame 2 5,5 1270x1430",
+				"SetMinimized 3 false",
+				"SetFrame 3 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+				"UnhideApp 30",
+				"Focus 3",
+			},
+		},
+		{
+			name:    "click right after a scroll is followed",
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, _ *fake.Backend) {
+				d.Dispatch(call("scroll-right", 0))
+				d.Settle()
+				d.HandleEvent(wm.Event{Kind: wm.EventFocused, PID: 10, ID: 1})
+			},
+			want: []string{
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+				"Focus 2",
+				"HideApp 30",
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+				"HideApp 30",
+			},
+		},
+		{
+			name:    "echoes of our own focus calls are ignored",
+			windows: []fake.Window{window(1, 10, 0), window(2, 20, 0), window(3, 30, 0)},
+			do: func(d *Daemon, _ *fake.Backend) {
+				d.Dispatch(call("scroll-right", 0))
+				d.Settle()
+				d.Dispatch(call("scroll-left", 0))
+				d.Settle()
+				d.HandleEvent(wm.Event{Kind: wm.EventFocused, PID: 20, ID: 2})
+				d.HandleEvent(wm.Event{Kind: wm.EventFocused, PID: 10})
+			},
+			want: []string{
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+				"Focus 2",
+				"HideApp 30",
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 1285,5 1270x1430",
+				"UnhideApp 10",
+				"UnhideApp 20",
+				"Focus 1",
+				"HideApp 30",
+			},
+		},
+		{
+			name:     "window moves to the next display",
+			displays: twoDisplays,
+			windows:  []fake.Window{window(1, 10, 100), window(2, 20, 2700)},
+			do: func(d *Daemon, _ *fake.Backend) {
+				d.Dispatch(call("move-window-next-display", 0))
+			},
+			want: []string{
+				"SetFrame 2 2565,5 950x1070",
+				"SetFrame 1 3525,5 950x1070",
+				"UnhideApp 20",
+				"UnhideApp 10",
+				"Focus 1",
+			},
+		},
+		{
+			name:     "window opens on the display it is on",
+			displays: twoDisplays,
+			windows:  []fake.Window{window(1, 10, 100)},
+			do: func(d *Daemon, b *fake.Backend) {
+				b.AddWindow(window(2, 20, 3000))
+				d.Reconcile()
+			},
+			want: []string{
+				"SetFrame 1 5,5 1270x1430",
+				"SetFrame 2 2565,5 950x1070",
+				"UnhideApp 10",
+				"UnhideApp 20",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			if tt.config != nil {
+				tt.config(&cfg)
+			}
+			b := fake.New(tt.displays...)
+			for _, w := range tt.windows {
+				b.AddWindow(w)
+			}
+			d := New(b, cfg, io.Discard)
+			d.Start()
+			d.ApplyLayout()
+			b.ResetCalls()
+
+			tt.do(d, b)
+			d.Settle()
+			if got := changes(b.Calls()); !slices.Equal(got, tt.want) {
+				t.Errorf("calls:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(tt.want, "\n  "))
+			}
+		})
+	}
+}
+
+func TestDisplayReplug(t *testing.T) {
+	displays := []wm.Display{
+		{ID: 1, Width: 2560, Height: 1440, Main: true},
+		{ID: 2, X: 2560, Width: 1920, Height: 1080},
+		{ID: 3, X: 4480, Width: 1920, Height: 1080},
+	}
+	unplug := func(d *Daemon, b *fake.Backend) {
+		b.SetDisplays(displays[:2]...)
+		run(d, wm.Event{Kind: wm.EventDisplaysChanged})
+	}
+	replug := func(d *Daemon, b *fake.Backend) {
+		b.SetDisplays(displays...)
+		d.SyncDisplays()
+	}
+	tests := []struct {
+		name string
+		do   func(d *Daemon, b *fake.Backend)
+		want map[uint32]uint32 // display of each window
+		home uint32            // of window 3's column
+	}{
+		{
+			name: "unplugged display's windows move to the first",
+			do:   unplug,
+			want: map[uint32]uint32{1: 1, 2: 2, 3: 1},
+			home: 3,
+		},
+		{
+			name: "replugged display gets its windows back",
+			do: func(d *Daemon, b *fake.Backend) {
+				unplug(d, b)
+				d.Settle()
+				replug(d, b)
+			},
+			want: map[uint32]uint32{1: 1, 2: 2, 3: 3},
+		},
+		{
+			name: "unplugging twice keeps the first home",
+			do: func(d *Daemon, b *fake.Backend) {
+				unplug(d, b)
+				d.Settle()
+				b.SetDisplays(displays[0])
+				d.SyncDisplays()
+			},
+			want: map[uint32]uint32{1: 1, 2: 1, 3: 1},
+			home: 3,
+		},
+		{
+			name: "column moved on purpose stays where it was put",
+			do: func(d *Daemon, b *fake.Backend) {
+				unplug(d, b)
+				d.Settle()
+				run(d, wm.Event{Kind: wm.EventFocused, PID: 30, ID: 3})
+				d.Dispatch(call("move-column-next-display", 0))
+				d.Settle()
+				replug(d, b)
+			},
+			want: map[uint32]uint32{1: 1, 2: 2, 3: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := fake.New(displays...)
+			b.AddWindow(window(1, 10, 100))
+			b.AddWindow(window(2, 20, 2700))
+			b.AddWindow(window(3, 30, 4600))
+			d := New(b, config.Default(), io.Discard)
+			d.Start()
+			d.ApplyLayout()
+
+			tt.do(d, b)
+			d.Settle()
+			d.Displays.Mutex.Lock()
+			defer d.Displays.Mutex.Unlock()
+			for id, want := range tt.want {
+				s := d.Displays.FindWindow(id)
+				if s == nil {
+					t.Errorf("window %d: not in a strip, want display %d", id, want)
+					continue
+				}
+				if s.DisplayID != want {
+					t.Errorf("window %d: on display %d, want %d", id, s.DisplayID, want)
+					continue
+				}
+				// Windows past the visible columns are hidden with their app
+				shown, _ := b.AppWindows(id * 10)
+				if f, _ := b.Frame(id*10, id); len(shown) > 0 && !displays[want-1].Contains(f.X, f.Y) {
+					t.Errorf("window %d: frame %+v, want it on display %d", id, f, want)
+				}
+				if id != 3 {
+					continue
+				}
+				for _, col := range s.Columns {
+					if slices.ContainsFunc(col.Windows, func(w *strip.Window) bool { return w.ID == id }) && col.Home != tt.home {
+						t.Errorf("window 3: column home %d, want %d", col.Home, tt.home)
+					}
+				}
+			}
+		})
+	}
+}
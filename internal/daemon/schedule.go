@@ -0,0 +1,124 @@
+package daemon
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/machina/mosaico/internal/layout"
+)
+
+// A layout runs layoutDebounce after the last request, but no later than
+// maxLayoutLatency after the first one still pending.
+const (
+	layoutDebounce   = 15 * time.Millisecond
+	maxLayoutLatency = 60 * time.Millisecond
+)
+
+// saveInterval is the least time between two saves of the state file, which
+// would otherwise be written on every scroll step.
+const saveInterval = time.Second
+
+// layoutQueue coalesces layout requests into one pass on the latest state.
+type layoutQueue struct {
+	mu    sync.Mutex
+	timer *time.Timer
+	// armed counts the timers started, so a callback can tell whether its
+	// timer is still the current one.
+	armed uint64
+	first time.Time
+	focus bool
+	// pending counts the scheduled passes that haven't finished.
+	pending sync.WaitGroup
+	// saveTimer is set while a save of the state file is pending, and
+	// saved is when the last one ran.
+	saveTimer *time.Timer
+	saved     time.Time
+	// gen is bumped by every strip change that makes a running pass stale.
+	gen atomic.Uint64
+}
+
+// supersede stops any running pass at its next window. Call it before
+// taking the displays lock, so the pass lets go of it sooner.
+func (q *layoutQueue) supersede() {
+	q.gen.Add(1)
+}
+
+func (q *layoutQueue) superseded(gen uint64) bool {
+	return q.gen.Load() != gen
+}
+
+// scheduleLayout asks for a layout pass soon, focusing the current window
+// afterwards if focus is set.
+func (d *Daemon) scheduleLayout(focus bool) {
+	q := &d.layouts
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.focus = q.focus || focus
+	now := time.Now()
+	if q.timer != nil {
+		wait := min(layoutDebounce, q.first.Add(maxLayoutLatency).Sub(now))
+		if q.timer.Stop() {
+			q.timer.Reset(max(wait, 0))
+			return
+		}
+		// The timer already fired, and Reset would fire it again. Its
+		// callback is waiting for the lock and will see it was replaced.
+	}
+	q.first = now
+	q.armed++
+	armed := q.armed
+	q.pending.Add(1)
+	q.timer = time.AfterFunc(layoutDebounce, func() { d.runScheduledLayout(armed) })
+}
+
+func (d *Daemon) runScheduledLayout(armed uint64) {
+	q := &d.layouts
+	defer q.pending.Done()
+	q.mu.Lock()
+	if armed != q.armed {
+		q.mu.Unlock()
+		return
+	}
+	focus := q.focus
+	q.focus = false
+	q.timer = nil
+	q.mu.Unlock()
+
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+	d.applyLayout(focus)
+}
+
+// scheduleSave saves the state file soon, unless a save is already
+// pending; it will pick up the latest state when it runs.
+func (d *Daemon) scheduleSave() {
+	if d.StatePath == "" {
+		return
+	}
+	q := &d.layouts
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.saveTimer == nil {
+		q.saveTimer = time.AfterFunc(max(saveInterval-time.Since(q.saved), 0), d.saveState)
+	}
+}
+
+func (d *Daemon) saveState() {
+	q := &d.layouts
+	q.mu.Lock()
+	q.saveTimer, q.saved = nil, time.Now()
+	q.mu.Unlock()
+
+	d.Displays.Mutex.Lock()
+	defer d.Displays.Mutex.Unlock()
+	if err := layout.SaveState(d.StatePath, d.state(d.currentDisplays())); err != nil {
+		d.logf("ERROR SaveState: %v\n", err)
+	}
+}
+
+// Settle waits until every scheduled layout has run.
+func (d *Daemon) Settle() {
+	d.layouts.pending.Wait()
+}
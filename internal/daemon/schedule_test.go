@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/machina/mosaico/internal/config"
+	"github.com/machina/mosaico/internal/wm/fake"
+)
+
+// TestScheduleLayoutConcurrently requests layouts from many goroutines
+// across several debounce periods, so requests keep landing while timers
+// fire.
+func TestScheduleLayoutConcurrently(t *testing.T) {
+	b := fake.New()
+	b.AddWindow(window(1, 10, 0))
+	b.AddWindow(window(2, 20, 0))
+	d := New(b, config.Default(), io.Discard)
+	d.Start()
+
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(5 * layoutDebounce)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				d.scheduleLayout(i%2 == 0)
+				time.Sleep(time.Duration(i) * time.Millisecond / 4)
+			}
+		}()
+	}
+	wg.Wait()
+	d.Settle()
+
+	d.layouts.mu.Lock()
+	defer d.layouts.mu.Unlock()
+	if d.layouts.timer != nil || d.layouts.focus {
+		t.Errorf("a layout is still pending after Settle")
+	}
+}
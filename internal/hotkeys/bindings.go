@@ -0,0 +1,242 @@
+package hotkeys
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/machina/mosaico/internal/actions"
+	"github.com/machina/mosaico/internal/config"
+)
+
+// Binding is a key sequence bound to an action and its arguments, or to
+// entering a mode.
+type Binding struct {
+	Keys []Combo
+	Call actions.Call
+	// Mode is the mode the binding switches to, for "mode NAME" actions.
+	// Call is unset then.
+	Mode string
+
+	source string // the keys as written in the config
+	layer  int    // which table passed to ParseBindings it came from
+}
+
+// ParseKeys parses a key sequence such as "ctrl+a w": combos separated by
+// spaces, pressed one after the other.
+func ParseKeys(s string) ([]Combo, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, errors.New("empty key sequence")
+	}
+	keys := make([]Combo, len(fields))
+	for i, f := range fields {
+		c, err := ParseCombo(f)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = c
+	}
+	return keys, nil
+}
+
+// DefaultBindings turns the [hotkeys] section into bindings: the modifier
+// with a key scrolls or focuses, the move modifier with the same key moves.
+// A modifier that is empty, invalid or the same as the other leaves its
+// bindings out, and is reported.
+func DefaultBindings(hk config.HotkeyConfig) (map[string]string, error) {
+	var errs []error
+	masks := make([]int, 2)
+	for i, m := range []struct{ name, mods string }{{"modifier", hk.Modifier}, {"move_modifier", hk.MoveModifier}} {
+		mask, err := ParseModifiers(m.mods)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("[hotkeys] %s: %w; its bindings are skipped", m.name, err))
+			mask = -1
+		case mask&^ModShift == 0:
+			errs = append(errs, fmt.Errorf("[hotkeys] %s %q would take over typing; its bindings are skipped",
+				m.name, m.mods))
+			mask = -1
+		case i == 1 && mask == masks[0]:
+			errs = append(errs, fmt.Errorf("[hotkeys] move_modifier %q is the same as modifier; "+
+				"the move bindings are skipped", m.mods))
+			mask = -1
+		}
+		masks[i] = mask
+	}
+
+	table := make(map[string]string)
+	for i, mods := range []string{hk.Modifier, hk.MoveModifier} {
+		if masks[i] < 0 {
+			continue
+		}
+		move := i == 1
+		bind := func(key, plain, moved string) {
+			action := plain
+			if move {
+				action = moved
+			}
+			if key != "" && action != "" {
+				table[mods+"+"+key] = action
+			}
+		}
+		bind(hk.ScrollLeft, "scroll-left", "move-left")
+		bind(hk.ScrollRight, "scroll-right", "move-right")
+		bind(hk.FocusUp, "focus-up", "move-up")
+		bind(hk.FocusDown, "focus-down", "move-down")
+		bind(hk.NextDisplay, "focus-next-display", "move-window-next-display")
+		bind(hk.PrevDisplay, "focus-prev-display", "move-window-prev-display")
+		bind(hk.MoveColumnNextDisplay, "", "move-column-next-display")
+		bind(hk.MoveColumnPrevDisplay, "", "move-column-prev-display")
+		bind(hk.CloseWindow, "close-window", "close-column")
+		bind(hk.MinimizeWindow, "minimize-window", "")
+		bind(hk.QuitApp, "quit-app", "")
+		// 0 is column 10, and digits typed in quick succession make one
+		// number, for columns past 9
+		for n := 1; n <= 10; n++ {
+			bind(strconv.Itoa(n%10), "jump-to-column "+strconv.Itoa(n), "move-to-column "+strconv.Itoa(n))
+		}
+	}
+	return table, errors.Join(errs...)
+}
+
+// Keymap is a set of bindings. Sequences nest: the first key of
+// "ctrl+a w" leads to a keymap holding w.
+type Keymap struct {
+	bindings map[Combo]Binding
+	prefixes map[Combo]*Keymap
+}
+
+func newKeymap() *Keymap {
+	return &Keymap{bindings: make(map[Combo]Binding), prefixes: make(map[Combo]*Keymap)}
+}
+
+// bind adds a binding and returns the ones it replaced: the one with the
+// same keys, and any that it or that a prefix of it would shadow.
+func (k *Keymap) bind(b Binding) (replaced []Binding) {
+	for _, c := range b.Keys[:len(b.Keys)-1] {
+		if old, ok := k.bindings[c]; ok {
+			replaced = append(replaced, old)
+			delete(k.bindings, c)
+		}
+		next, ok := k.prefixes[c]
+		if !ok {
+			next = newKeymap()
+			k.prefixes[c] = next
+		}
+		k = next
+	}
+	last := b.Keys[len(b.Keys)-1]
+	if next, ok := k.prefixes[last]; ok {
+		replaced = append(replaced, next.Bindings()...)
+		delete(k.prefixes, last)
+	}
+	if old, ok := k.bindings[last]; ok {
+		replaced = append(replaced, old)
+	}
+	k.bindings[last] = b
+	return replaced
+}
+
+func (k *Keymap) unbind(keys []Combo) {
+	for _, c := range keys[:len(keys)-1] {
+		if k = k.prefixes[c]; k == nil {
+			return
+		}
+	}
+	delete(k.bindings, keys[len(keys)-1])
+}
+
+// Bindings lists every binding in the keymap, sequences included.
+func (k *Keymap) Bindings() []Binding {
+	var all []Binding
+	for _, b := range k.bindings {
+		all = append(all, b)
+	}
+	for _, next := range k.prefixes {
+		all = append(all, next.Bindings()...)
+	}
+	slices.SortFunc(all, func(a, b Binding) int { return strings.Compare(keysString(a.Keys), keysString(b.Keys)) })
+	return all
+}
+
+func keysString(keys []Combo) string {
+	s := make([]string, len(keys))
+	for i, c := range keys {
+		s[i] = c.String()
+	}
+	return strings.Join(s, " ")
+}
+
+// parseBinding parses one entry of a bindings table. modes lists the mode
+// names "mode NAME" may switch to.
+func parseBinding(keys, act string, modes map[string]bool) (Binding, error) {
+	combos, err := ParseKeys(keys)
+	if err != nil {
+		return Binding{}, fmt.Errorf("binding %w", err)
+	}
+	if fields := strings.Fields(act); len(fields) > 0 && fields[0] == "mode" {
+		if len(fields) != 2 || !modes[fields[1]] {
+			return Binding{}, fmt.Errorf("binding %q: %q: no such mode", keys, act)
+		}
+		return Binding{Keys: combos, Mode: fields[1], source: keys}, nil
+	}
+	call, err := actions.Parse(act)
+	if err != nil {
+		return Binding{}, fmt.Errorf("binding %q: %w", keys, err)
+	}
+	return Binding{Keys: combos, Call: call, source: keys}, nil
+}
+
+// ParseBindings parses the bindings table of each layer in turn into one
+// keymap, later layers overriding earlier ones for the same keys. An action
+// of "" or "none" removes a binding. Unless modal is set, a binding must
+// start with a modifier, or it would take over typing. Invalid entries are
+// skipped; they, and bindings that collide within a layer or shadow each
+// other, are reported.
+func ParseBindings(modes map[string]bool, modal bool, layers ...map[string]string) (*Keymap, error) {
+	km := newKeymap()
+	var errs []error
+	for layer, table := range layers {
+		entries := make([]string, 0, len(table))
+		for keys := range table {
+			entries = append(entries, keys)
+		}
+		slices.Sort(entries)
+
+		for _, keys := range entries {
+			if a := strings.TrimSpace(table[keys]); a == "" || a == "none" {
+				combos, err := ParseKeys(keys)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("binding %w", err))
+					continue
+				}
+				km.unbind(combos)
+				continue
+			}
+			b, err := parseBinding(keys, table[keys], modes)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !modal && b.Keys[0].types() {
+				errs = append(errs, fmt.Errorf("binding %q has no modifier and would take over typing %s; "+
+					"skipped (bare keys belong in [modes])", keys, b.Keys[0]))
+				continue
+			}
+			b.layer = layer
+			for _, old := range km.bind(b) {
+				switch {
+				case !slices.Equal(old.Keys, b.Keys):
+					errs = append(errs, fmt.Errorf("binding %q shadows %q, which is dropped", keys, old.source))
+				case old.layer == layer:
+					errs = append(errs, fmt.Errorf("bindings %q and %q are the same keys; using %q",
+						old.source, keys, keys))
+				}
+			}
+		}
+	}
+	return km, errors.Join(errs...)
+}
@@ -0,0 +1,71 @@
+package hotkeys
+
+import (
+	"fmt"
+	"slices"
+)
+
+// functionKeys are the keys that type nothing even without modifiers.
+var functionKeys = []string{
+	"f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9", "f10",
+	"f11", "f12", "f13", "f14", "f15", "f16", "f17", "f18", "f19", "f20",
+}
+
+// types reports whether the combo is plain typing: no modifier but shift,
+// on a key other than a function key.
+func (c Combo) types() bool {
+	if c.Modifiers&^ModShift != 0 {
+		return false
+	}
+	return !slices.ContainsFunc(functionKeys, func(name string) bool { return namedKeys[name] == c.Key })
+}
+
+// systemShortcuts are macOS shortcuts that bindings would take over, with
+// what they do.
+var systemShortcuts = map[string]string{
+	"cmd+tab":        "the app switcher",
+	"cmd+shift+tab":  "the app switcher",
+	"cmd+`":          "cycling an app's windows",
+	"cmd+space":      "Spotlight",
+	"ctrl+space":     "switching input sources",
+	"ctrl+cmd+space": "the Character Viewer",
+	"cmd+q":          "quitting apps",
+	"cmd+w":          "closing windows",
+	"cmd+h":          "hiding apps",
+	"cmd+alt+h":      "hiding other apps",
+	"cmd+m":          "minimizing windows",
+	"cmd+,":          "app settings",
+	"cmd+alt+escape": "Force Quit",
+	"ctrl+cmd+q":     "Lock Screen",
+	"ctrl+cmd+f":     "full screen",
+	"cmd+alt+d":      "showing and hiding the Dock",
+	"cmd+shift+3":    "screenshots",
+	"cmd+shift+4":    "screenshots",
+	"cmd+shift+5":    "screenshots",
+	"cmd+shift+/":    "the Help menu",
+	"ctrl+up":        "Mission Control",
+	"ctrl+down":      "App Exposé",
+	"ctrl+left":      "moving between Spaces",
+	"ctrl+right":     "moving between Spaces",
+}
+
+// checkSystem reports bindings in km whose first key is a macOS system
+// shortcut. They still work; mosaico gets the keys first.
+func checkSystem(km *Keymap) []error {
+	system := make(map[Combo]string)
+	for keys, what := range systemShortcuts {
+		if c, err := ParseCombo(keys); err == nil {
+			system[c] = what
+		}
+	}
+	var errs []error
+	seen := make(map[Combo]bool)
+	for _, b := range km.Bindings() {
+		c := b.Keys[0]
+		if what, ok := system[c]; ok && !seen[c] {
+			seen[c] = true
+			errs = append(errs, fmt.Errorf("binding %q takes %s away from %s", b.source, c, what))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,64 @@
+package hotkeys
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/machina/mosaico/internal/actions"
+)
+
+// job is an action waiting to run. Repeat ticks carry the repeater's gen,
+// so a tick still queued when its key goes up is skipped.
+type job struct {
+	call actions.Call
+	tick bool
+	gen  int
+}
+
+// jobs holds the actions waiting for the dispatch goroutine. The event
+// tap only queues them: macOS disables a tap whose callback is slow, and
+// running an action waits for the layout lock.
+var (
+	jobs          = make(chan job, 64)
+	tickQueued    atomic.Bool
+	startDispatch sync.Once
+)
+
+func dispatch() {
+	for j := range jobs {
+		if j.tick {
+			tickQueued.Store(false)
+			if !repeats.live(j.gen) {
+				continue
+			}
+		}
+		run(j.call)
+	}
+}
+
+// runCall queues an action without blocking.
+func runCall(c actions.Call) {
+	if run == nil {
+		return
+	}
+	select {
+	case jobs <- job{call: c}:
+	default:
+		fmt.Printf("WARNING: too many actions queued, dropping %s\n", c)
+	}
+}
+
+// runTick queues a repeat of a held key's action, unless one is still
+// waiting: when actions run slower than the key repeats, ticks are dropped
+// rather than piling up and running on after the key is released.
+func runTick(c actions.Call, gen int) {
+	if run == nil || !tickQueued.CompareAndSwap(false, true) {
+		return
+	}
+	select {
+	case jobs <- job{call: c, tick: true, gen: gen}:
+	default:
+		tickQueued.Store(false)
+	}
+}
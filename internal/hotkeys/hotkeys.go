@@ -1,185 +1,161 @@
 package hotkeys
 
-/*
-#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation
-#include <CoreGraphics/CoreGraphics.h>
-
-void hotkeyCallback(int keyCode, int modifiers);
-
-static CGEventRef eventTapCallback(CGEventTapProxy proxy, CGEventType type, CGEventRef event, void *userInfo) {
-	if(type == kCGEventKeyDown) {
-		CGKeyCode keyCode = (CGKeyCode)CGEventGetIntegerValueField(event, kCGKeyboardEventKeycode);
-		CGEventFlags flags = CGEventGetFlags(event);
-		hotkeyCallback((int)keyCode, (int)flags);
-	}
-	return event;
-}
-
-static CFMachPortRef createEventTap() {
-    return CGEventTapCreate(
-        kCGSessionEventTap,
-        kCGHeadInsertEventTap,
-        kCGEventTapOptionDefault,
-        (CGEventMask)(1 << kCGEventKeyDown),
-        eventTapCallback,
-        NULL
-    );
-}
-*/
-import "C"
-
 import (
-	"strings"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/machina/mosaico/internal/actions"
 	"github.com/machina/mosaico/internal/config"
 )
 
-type Command int
-
-const (
-	CmdScrollLeft Command = iota
-	CmdScrollRight
-	CmdFocusUp
-	CmdFocusDown
-)
-
-var Commands = make(chan Command, 10)
+// hotkeys is what the event tap works from: the keymaps and their mode
+// state and the scroll gesture in progress. The per-app policies are kept
+// by filter.
+type hotkeys struct {
+	keys     *machine
+	scroller Scroller
+}
 
 var (
-	modifierMask     int
-	moveModifierMask int
-	keyScrollLeft    int
-	keyScrollRight   int
-	keyFocusUp       int
-	keyFocusDown     int
+	// active is the instance built by Configure.
+	active *hotkeys
+	// configured is the config active was built from, kept to rebuild it
+	// for a new keyboard layout.
+	configured  config.Config
+	run         func(c actions.Call)
+	onMode      func(mode string)
+	columnCount func() int
 )
 
-var handlers Handlers
-
-type Handlers struct {
-	ScrollLeft      func()
-	ScrollRight     func()
-	FocusUp         func()
-	FocusDown       func()
-	MoveWindowRight func()
-	MoveWindowLeft  func()
-	MoveWindowUp    func()
-	MoveWindowDown  func()
-	JumpToColumn    func(int)
-	MoveToColumn    func(int)
-}
-
-// macOS keycodes for number keys 1-9
-var numberKeyCodes = map[int]int{
-	18: 1, 19: 2, 20: 3, 21: 4, 23: 5,
-	22: 6, 26: 7, 28: 8, 25: 9,
-}
-
-func Configure(cfg config.HotkeyConfig) {
-	modifierMask = ParseModifier(cfg.Modifier)
-	moveModifierMask = ParseModifier(cfg.MoveModifier)
-	keyScrollLeft = ParseKey(cfg.ScrollLeft)
-	keyScrollRight = ParseKey(cfg.ScrollRight)
-	keyFocusUp = ParseKey(cfg.FocusUp)
-	keyFocusDown = ParseKey(cfg.FocusDown)
-}
-
-func SetHandlers(h Handlers) {
-	handlers = h
-}
+// Configure sets the keymaps from the [hotkeys], [bindings] and [modes]
+// sections, scrolling from [scroll], and the per-app hotkeys policies from
+// [apps]. Invalid entries
+// are skipped; the returned error lists them.
+func Configure(cfg config.Config) error {
+	configured = cfg
+	names := map[string]bool{DefaultMode: true}
+	for name := range cfg.Modes {
+		names[name] = true
+	}
 
-//export hotkeyCallback
-func hotkeyCallback(keyCode C.int, modifiers C.int) {
-	if int(modifiers)&moveModifierMask == moveModifierMask {
-		// Check for number keys (1-9) to move window to column
-		if colNum, ok := numberKeyCodes[int(keyCode)]; ok {
-			if handlers.MoveToColumn != nil {
-				handlers.MoveToColumn(colNum)
-			}
-			return
+	var errs []error
+	modes := make(map[string]*Keymap)
+	defaults, err := DefaultBindings(cfg.Hotkeys)
+	errs = append(errs, err)
+	km, err := ParseBindings(names, false, defaults, cfg.Bindings)
+	errs = append(errs, err)
+	errs = append(errs, checkSystem(km)...)
+	modes[DefaultMode] = km
+	for name, table := range cfg.Modes {
+		if name == DefaultMode {
+			errs = append(errs, fmt.Errorf("[modes.%s]: the default mode is [bindings]", name))
+			continue
 		}
-
-		switch int(keyCode) {
-		case keyScrollLeft:
-			if handlers.MoveWindowLeft != nil {
-				handlers.MoveWindowLeft()
-			}
-		case keyScrollRight:
-			if handlers.MoveWindowRight != nil {
-				handlers.MoveWindowRight()
-			}
-		case keyFocusUp:
-			if handlers.MoveWindowUp != nil {
-				handlers.MoveWindowUp()
-			}
-		case keyFocusDown:
-			if handlers.MoveWindowDown != nil {
-				handlers.MoveWindowDown()
+		km, err := ParseBindings(names, true, table)
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				errs = append(errs, fmt.Errorf("[modes.%s] %w", name, err))
 			}
 		}
+		modes[name] = km
+	}
 
-	} else if int(modifiers)&modifierMask == modifierMask {
-		// Check for number keys (1-9) to jump to column
-		if colNum, ok := numberKeyCodes[int(keyCode)]; ok {
-			if handlers.JumpToColumn != nil {
-				handlers.JumpToColumn(colNum)
-			}
-			return
-		}
+	if active != nil && active.keys.stop() != DefaultMode {
+		modeChanged(DefaultMode)
+	}
+	h := &hotkeys{
+		keys: newMachine(modes, seconds(cfg.Hotkeys.ModeTimeout), seconds(cfg.Hotkeys.ChordTimeout)),
+	}
 
-		switch int(keyCode) {
-		case keyScrollLeft:
-			if handlers.ScrollLeft != nil {
-				handlers.ScrollLeft()
-			}
-		case keyScrollRight:
-			if handlers.ScrollRight != nil {
-				handlers.ScrollRight()
-			}
-		case keyFocusUp:
-			if handlers.FocusUp != nil {
-				handlers.FocusUp()
-			}
-		case keyFocusDown:
-			if handlers.FocusDown != nil {
-				handlers.FocusDown()
-			}
-		}
+	mode, err := ParseRepeatMode(cfg.Hotkeys.Repeat)
+	errs = append(errs, err)
+	repeats.configure(Repeat{
+		Mode:    mode,
+		Rate:    cfg.Hotkeys.RepeatRate,
+		MaxRate: cfg.Hotkeys.RepeatMaxRate,
+		Ramp:    seconds(cfg.Hotkeys.RepeatRamp),
+	})
+
+	scroll, err := ParseScrollMode(cfg.Scroll.Mode)
+	errs = append(errs, err)
+	scrollMods, err := ParseModifiers(cfg.Scroll.Modifiers)
+	errs = append(errs, err)
+	h.scroller = Scroller{
+		Mode:        scroll,
+		Modifiers:   scrollMods,
+		Swipe:       cfg.Scroll.Swipe,
+		Sensitivity: cfg.Scroll.Sensitivity,
+		Invert:      cfg.Scroll.Invert,
 	}
 
+	policies := make(map[string]AppPolicy)
+	for bundleID, app := range cfg.Apps {
+		policy, err := ParseAppPolicy(app.Hotkeys)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", bundleID, err))
+			continue
+		}
+		if policy != PolicyConsume {
+			policies[bundleID] = policy
+		}
+	}
+	filter.configure(policies)
+	active = h
+	return errors.Join(errs...)
 }
 
-func StartEventTap() {
-	tap := C.createEventTap()
-	runLoopSource := C.CFMachPortCreateRunLoopSource(C.kCFAllocatorDefault, tap, 0)
-	C.CFRunLoopAddSource(C.CFRunLoopGetCurrent(), runLoopSource, C.kCFRunLoopCommonModes)
-	C.CGEventTapEnable(tap, C.bool(true))
-	C.CFRunLoopRun()
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
 }
 
-func ParseModifier(s string) int {
-	mask := 0
-	if strings.Contains(s, "ctrl") {
-		mask |= 0x40000
-	}
-	if strings.Contains(s, "cmd") {
-		mask |= 0x100000
-	}
-	if strings.Contains(s, "alt") || strings.Contains(s, "opt") {
-		mask |= 0x80000
+// keyboardChanged rebinds every key after the input source changed, so
+// bindings follow the new layout.
+func keyboardChanged() {
+	if !loadKeyboard() || active == nil {
+		return
 	}
-	if strings.Contains(s, "shift") {
-		mask |= 0x20000
+	fmt.Println("Keyboard layout changed, rebinding hotkeys")
+	if err := Configure(configured); err != nil {
+		fmt.Printf("WARNING: %v\n", err)
 	}
-	return mask
 }
 
-// Key name to keycode
-func ParseKey(s string) int {
-	keys := map[string]int{
-		"h": 4, "j": 38, "k": 40, "l": 37,
-		"a": 0, "s": 1, "d": 2, "f": 3,
-		// add more as needed
+// SetRunner sets the function bound actions are run with. It is called
+// on a goroutine of its own, one action at a time.
+func SetRunner(f func(c actions.Call)) {
+	run = f
+	startDispatch.Do(func() { go dispatch() })
+}
+
+// SetColumnCount sets a function returning how many columns can be jumped
+// to, so a typed column number runs as soon as no further digit can name
+// one. It is called from the event tap and must not block. Without it,
+// column numbers wait for the digit timeout.
+func SetColumnCount(f func() int) {
+	columnCount = f
+}
+
+// OnModeChanged sets a function called with the name of the new mode
+// whenever a key or the mode timeout switches modes.
+func OnModeChanged(f func(mode string)) {
+	onMode = f
+}
+
+// handleKey feeds a key-down event to the keymaps and runs the action it
+// completes, if any. Modifiers must match exactly. It reports whether the
+// key belonged to mosaico.
+func (h *hotkeys) handleKey(keyCode, modifiers int) bool {
+	p := h.keys.press(Combo{Modifiers: modifiers & modMask, Key: keyCode})
+	if p.mode != "" {
+		modeChanged(p.mode)
+	}
+	if p.number != nil {
+		runCall(*p.number)
+	}
+	if p.call != nil {
+		runCall(*p.call)
 	}
-	return keys[strings.ToLower(s)]
+	repeats.hold(keyCode, p.call)
+	return p.handled
 }
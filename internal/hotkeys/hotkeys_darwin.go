@@ -0,0 +1,135 @@
+//go:build darwin && cgo
+
+package hotkeys
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation -framework AppKit
+#include <CoreGraphics/CoreGraphics.h>
+#import <AppKit/AppKit.h>
+
+int hotkeyCallback(int keyCode, int modifiers, int repeat);
+int hotkeyUpCallback(int keyCode);
+int scrollCallback(double dx, double dy, int modifiers, int continuous, int phase, int momentum);
+
+static CFMachPortRef tap;
+
+static CGEventRef eventTapCallback(CGEventTapProxy proxy, CGEventType type, CGEventRef event, void *userInfo) {
+	// macOS disables taps that are slow to answer; turn it back on
+	if (type == kCGEventTapDisabledByTimeout || type == kCGEventTapDisabledByUserInput) {
+		CGEventTapEnable(tap, true);
+		return event;
+	}
+	CGKeyCode keyCode = (CGKeyCode)CGEventGetIntegerValueField(event, kCGKeyboardEventKeycode);
+	if (type == kCGEventKeyDown) {
+		CGEventFlags flags = CGEventGetFlags(event);
+		int repeat = (int)CGEventGetIntegerValueField(event, kCGKeyboardEventAutorepeat);
+		if (hotkeyCallback((int)keyCode, (int)flags, repeat)) {
+			return NULL;
+		}
+	} else if (type == kCGEventKeyUp) {
+		if (hotkeyUpCallback((int)keyCode)) {
+			return NULL;
+		}
+	} else if (type == kCGEventScrollWheel) {
+		double dx = CGEventGetDoubleValueField(event, kCGScrollWheelEventPointDeltaAxis2);
+		double dy = CGEventGetDoubleValueField(event, kCGScrollWheelEventPointDeltaAxis1);
+		int continuous = (int)CGEventGetIntegerValueField(event, kCGScrollWheelEventIsContinuous);
+		int phase = (int)CGEventGetIntegerValueField(event, kCGScrollWheelEventScrollPhase);
+		int momentum = (int)CGEventGetIntegerValueField(event, kCGScrollWheelEventMomentumPhase);
+		if (scrollCallback(dx, dy, (int)CGEventGetFlags(event), continuous, phase, momentum)) {
+			return NULL;
+		}
+	}
+	return event;
+}
+
+static CFMachPortRef createEventTap() {
+    tap = CGEventTapCreate(
+        kCGSessionEventTap,
+        kCGHeadInsertEventTap,
+        kCGEventTapOptionDefault,
+        (CGEventMask)((1 << kCGEventKeyDown) | (1 << kCGEventKeyUp) | (1 << kCGEventScrollWheel)),
+        eventTapCallback,
+        NULL
+    );
+    return tap;
+}
+
+// frontBundleID copies the bundle ID of the frontmost app into buf.
+static void frontBundleID(char *buf, int size) {
+	buf[0] = 0;
+	@autoreleasepool {
+		NSString *id = [[[NSWorkspace sharedWorkspace] frontmostApplication] bundleIdentifier];
+		if (id != nil) {
+			strlcpy(buf, [id UTF8String], size);
+		}
+	}
+}
+*/
+import "C"
+
+import (
+	"fmt"
+)
+
+//export hotkeyCallback
+func hotkeyCallback(keyCode C.int, modifiers C.int, repeat C.int) C.int {
+	h := active
+	if h == nil {
+		return 0
+	}
+	front := ""
+	if filter.perApp() {
+		var buf [256]C.char
+		C.frontBundleID(&buf[0], C.int(len(buf)))
+		front = C.GoString(&buf[0])
+	}
+	if h.keyDown(int(keyCode), int(modifiers), front, repeat != 0) {
+		return 1
+	}
+	return 0
+}
+
+//export scrollCallback
+func scrollCallback(dx, dy C.double, modifiers, continuous, phase, momentum C.int) C.int {
+	h := active
+	if h == nil {
+		return 0
+	}
+	e := ScrollEvent{
+		DX:         float64(dx),
+		DY:         float64(dy),
+		Continuous: continuous != 0,
+		Momentum:   momentum != 0,
+		Began:      phase == C.kCGScrollPhaseBegan,
+		Ended:      phase == C.kCGScrollPhaseEnded || phase == C.kCGScrollPhaseCancelled || momentum == C.kCGMomentumScrollPhaseEnd,
+	}
+	if h.scrolled(e, int(modifiers)) {
+		return 1
+	}
+	return 0
+}
+
+//export hotkeyUpCallback
+func hotkeyUpCallback(keyCode C.int) C.int {
+	if keyUp(int(keyCode)) {
+		return 1
+	}
+	return 0
+}
+
+// StartEventTap listens for key events on the current run loop. It blocks
+// forever.
+func StartEventTap() error {
+	tap := C.createEventTap()
+	if tap == 0 {
+		return fmt.Errorf("failed to create event tap (is Accessibility permission granted?)")
+	}
+	runLoopSource := C.CFMachPortCreateRunLoopSource(C.kCFAllocatorDefault, tap, 0)
+	C.CFRunLoopAddSource(C.CFRunLoopGetCurrent(), runLoopSource, C.kCFRunLoopCommonModes)
+	C.CGEventTapEnable(tap, C.bool(true))
+	watchKeyboard()
+	C.CFRunLoopRun()
+	return nil
+}
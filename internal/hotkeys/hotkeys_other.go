@@ -0,0 +1,12 @@
+//go:build !darwin || !cgo
+
+package hotkeys
+
+import (
+	"errors"
+)
+
+// StartEventTap is only available on macOS, built with cgo.
+func StartEventTap() error {
+	return errors.New("global hotkeys are only supported on macOS, built with cgo")
+}
@@ -0,0 +1,186 @@
+package hotkeys
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CGEventFlags bits for the modifiers mosaico cares about.
+const (
+	ModShift = 0x20000
+	ModCtrl  = 0x40000
+	ModAlt   = 0x80000
+	ModCmd   = 0x100000
+
+	modMask = ModShift | ModCtrl | ModAlt | ModCmd
+)
+
+var modifierNames = map[string]int{
+	"shift": ModShift,
+	"ctrl":  ModCtrl, "control": ModCtrl,
+	"alt": ModAlt, "opt": ModAlt, "option": ModAlt,
+	"cmd": ModCmd, "command": ModCmd,
+	"hyper": ModCtrl | ModAlt | ModCmd | ModShift,
+	"meh":   ModCtrl | ModAlt | ModShift,
+}
+
+// ansiKeys maps the characters of the ANSI US layout to their keycodes. It
+// stands in for the current layout when that can't be read.
+var ansiKeys = map[string]int{
+	"a": 0, "s": 1, "d": 2, "f": 3, "h": 4, "g": 5, "z": 6, "x": 7, "c": 8, "v": 9,
+	"b": 11, "q": 12, "w": 13, "e": 14, "r": 15, "y": 16, "t": 17, "o": 31, "u": 32,
+	"i": 34, "p": 35, "l": 37, "j": 38, "k": 40, "n": 45, "m": 46,
+
+	"1": 18, "2": 19, "3": 20, "4": 21, "5": 23, "6": 22, "7": 26, "8": 28, "9": 25, "0": 29,
+
+	"=": 24, "-": 27, "]": 30, "[": 33, "'": 39, ";": 41, "\\": 42, ",": 43, "/": 44, ".": 47, "`": 50,
+}
+
+// charNames are names for characters, for when the character itself reads
+// badly in a config file.
+var charNames = map[string]string{
+	"equal": "=", "minus": "-", "rightbracket": "]", "leftbracket": "[", "quote": "'", "semicolon": ";",
+	"backslash": "\\", "comma": ",", "slash": "/", "period": ".", "grave": "`", "backtick": "`",
+}
+
+// namedKeys are the keys that type no character, the same on every layout.
+var namedKeys = map[string]int{
+	"return": 36, "enter": 36, "tab": 48, "space": 49, "delete": 51, "backspace": 51,
+	"escape": 53, "esc": 53, "forwarddelete": 117,
+	"home": 115, "end": 119, "pageup": 116, "pagedown": 121,
+	"left": 123, "right": 124, "down": 125, "up": 126,
+
+	"f1": 122, "f2": 120, "f3": 99, "f4": 118, "f5": 96, "f6": 97, "f7": 98, "f8": 100,
+	"f9": 101, "f10": 109, "f11": 103, "f12": 111, "f13": 105, "f14": 107, "f15": 113,
+	"f16": 106, "f17": 64, "f18": 79, "f19": 80, "f20": 90,
+}
+
+// keyboard is the current layout: which keycode types each character, and
+// back. Characters are resolved through it, so "h" is the key labelled H
+// on AZERTY, Dvorak and QWERTZ alike.
+var keyboard struct {
+	sync.Mutex
+	chars map[string]int
+	names map[int]string
+}
+
+func init() {
+	loadKeyboard()
+}
+
+// loadKeyboard reads the current layout, falling back to ANSI US. It
+// reports whether the layout changed.
+func loadKeyboard() bool {
+	chars := readKeyboard()
+	if len(chars) == 0 {
+		chars = ansiKeys
+	}
+	names := make(map[int]string)
+	for _, table := range []map[string]int{chars, namedKeys} {
+		for name, code := range table {
+			// Prefer the shortest name, then the first alphabetically
+			if old, ok := names[code]; !ok || len(name) < len(old) || (len(name) == len(old) && name < old) {
+				names[code] = name
+			}
+		}
+	}
+
+	keyboard.Lock()
+	defer keyboard.Unlock()
+	changed := !maps.Equal(chars, keyboard.chars)
+	keyboard.chars, keyboard.names = chars, names
+	return changed
+}
+
+// ParseKey returns the keycode for a key name: a character on the current
+// layout, a character name such as "comma", a named key such as "return",
+// or "keycode:NN" for a raw keycode.
+func ParseKey(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if n, ok := strings.CutPrefix(s, "keycode:"); ok {
+		code, err := strconv.Atoi(n)
+		if err != nil || code < 0 || code > 127 {
+			return 0, fmt.Errorf("bad keycode %q, want 0-127", n)
+		}
+		return code, nil
+	}
+	if code, ok := namedKeys[s]; ok {
+		return code, nil
+	}
+	if c, ok := charNames[s]; ok {
+		s = c
+	}
+	keyboard.Lock()
+	defer keyboard.Unlock()
+	if code, ok := keyboard.chars[s]; ok {
+		return code, nil
+	}
+	return 0, fmt.Errorf("unknown key %q", s)
+}
+
+func keyName(code int) string {
+	keyboard.Lock()
+	defer keyboard.Unlock()
+	if name, ok := keyboard.names[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("keycode:%d", code)
+}
+
+// ParseModifiers parses modifiers joined by "+", such as "ctrl+cmd+alt".
+func ParseModifiers(s string) (int, error) {
+	mask := 0
+	for _, name := range strings.Split(s, "+") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		m, ok := modifierNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown modifier %q", name)
+		}
+		mask |= m
+	}
+	return mask, nil
+}
+
+// Combo is a key pressed with an exact set of modifiers.
+type Combo struct {
+	Modifiers int
+	Key       int
+}
+
+// ParseCombo parses modifiers and a key joined by "+", such as
+// "ctrl+alt+shift+3". The key comes last; "+" itself is "equal" with shift.
+func ParseCombo(s string) (Combo, error) {
+	i := strings.LastIndex(s, "+")
+	key, mods := s[i+1:], ""
+	if i >= 0 {
+		mods = s[:i]
+	}
+	code, err := ParseKey(key)
+	if err != nil {
+		return Combo{}, fmt.Errorf("%q: %w", s, err)
+	}
+	mask, err := ParseModifiers(mods)
+	if err != nil {
+		return Combo{}, fmt.Errorf("%q: %w", s, err)
+	}
+	return Combo{Modifiers: mask, Key: code}, nil
+}
+
+func (c Combo) String() string {
+	var parts []string
+	for _, m := range []struct {
+		bit  int
+		name string
+	}{{ModCtrl, "ctrl"}, {ModAlt, "alt"}, {ModCmd, "cmd"}, {ModShift, "shift"}} {
+		if c.Modifiers&m.bit != 0 {
+			parts = append(parts, m.name)
+		}
+	}
+	return strings.Join(append(parts, keyName(c.Key)), "+")
+}
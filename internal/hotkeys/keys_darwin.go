@@ -0,0 +1,104 @@
+//go:build darwin && cgo
+
+package hotkeys
+
+/*
+#cgo LDFLAGS: -framework Carbon -framework CoreFoundation
+#include <Carbon/Carbon.h>
+
+void goKeyboardChanged(void);
+
+// keyChars writes what each of the first 128 keycodes types on the current
+// layout, without and with shift, as NUL-terminated UTF-8 in 8-byte slots.
+// It returns 0 when the layout has no Unicode data.
+static int keyChars(char *out) {
+	TISInputSourceRef src = TISCopyCurrentKeyboardLayoutInputSource();
+	if (src == NULL) {
+		return 0;
+	}
+	CFDataRef data = (CFDataRef)TISGetInputSourceProperty(src, kTISPropertyUnicodeKeyLayoutData);
+	if (data == NULL) {
+		CFRelease(src);
+		return 0;
+	}
+	const UCKeyboardLayout *layout = (const UCKeyboardLayout *)CFDataGetBytePtr(data);
+	for (int code = 0; code < 128; code++) {
+		for (int shift = 0; shift < 2; shift++) {
+			char *slot = out + (code*2 + shift)*8;
+			UInt32 dead = 0;
+			UniChar chars[4];
+			UniCharCount n = 0;
+			UInt32 mods = shift ? (shiftKey >> 8) & 0xFF : 0;
+			slot[0] = 0;
+			OSStatus err = UCKeyTranslate(layout, code, kUCKeyActionDown, mods, LMGetKbdType(),
+				kUCKeyTranslateNoDeadKeysMask, &dead, 4, &n, chars);
+			if (err != noErr || n == 0 || chars[0] < 0x20 || chars[0] == 0x7f) {
+				continue;
+			}
+			CFStringRef s = CFStringCreateWithCharacters(NULL, chars, n);
+			CFStringGetCString(s, slot, 8, kCFStringEncodingUTF8);
+			CFRelease(s);
+		}
+	}
+	CFRelease(src);
+	return 1;
+}
+
+static void keyboardCallback(CFNotificationCenterRef center, void *observer, CFNotificationName name,
+	const void *object, CFDictionaryRef info) {
+	goKeyboardChanged();
+}
+
+static void observeKeyboard(void) {
+	CFNotificationCenterAddObserver(CFNotificationCenterGetDistributedCenter(), NULL, keyboardCallback,
+		kTISNotifySelectedKeyboardInputSourceChanged, NULL, CFNotificationSuspensionBehaviorDeliverImmediately);
+}
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// keypad holds the keycodes of the numeric keypad, whose digits would
+// otherwise shadow the main row on layouts that need shift for digits.
+var keypad = map[int]bool{
+	65: true, 67: true, 69: true, 71: true, 75: true, 76: true, 78: true, 81: true, 82: true,
+	83: true, 84: true, 85: true, 86: true, 87: true, 88: true, 89: true, 91: true, 92: true,
+}
+
+// readKeyboard maps the characters of the current layout to keycodes.
+// Unshifted characters win over shifted ones, and lower keycodes over
+// higher ones.
+func readKeyboard() map[string]int {
+	var buf [128 * 2 * 8]C.char
+	if C.keyChars(&buf[0]) == 0 {
+		return nil
+	}
+	chars := make(map[string]int)
+	for shift := range 2 {
+		for code := range 128 {
+			if keypad[code] {
+				continue
+			}
+			s := C.GoString((*C.char)(unsafe.Pointer(&buf[(code*2+shift)*8])))
+			s = strings.ToLower(s)
+			if _, ok := chars[s]; s != "" && !ok {
+				chars[s] = code
+			}
+		}
+	}
+	return chars
+}
+
+// watchKeyboard rebinds the hotkeys whenever the input source changes. The
+// notification arrives on the current run loop.
+func watchKeyboard() {
+	C.observeKeyboard()
+}
+
+//export goKeyboardChanged
+func goKeyboardChanged() {
+	keyboardChanged()
+}
@@ -0,0 +1,9 @@
+//go:build !darwin || !cgo
+
+package hotkeys
+
+// readKeyboard can't read the layout without macOS and cgo; keys resolve as
+// on ANSI US.
+func readKeyboard() map[string]int {
+	return nil
+}
@@ -0,0 +1,261 @@
+package hotkeys
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/machina/mosaico/internal/actions"
+)
+
+// DefaultMode is the mode hotkeys start in, and the one escape and the
+// mode timeout return to.
+const DefaultMode = "default"
+
+var escape = Combo{Key: namedKeys["escape"]}
+
+// digitTimeout is how long a column number waits for a further digit, when
+// one could still name a column.
+const digitTimeout = 300 * time.Millisecond
+
+// machine tracks the active mode and any half-typed key sequence. Key
+// events and timers both drive it, so it has its own lock.
+type machine struct {
+	mu      sync.Mutex
+	modes   map[string]*Keymap
+	mode    string
+	pending *Keymap // the rest of a sequence being typed, or nil
+
+	modeTimeout  time.Duration
+	chordTimeout time.Duration
+	digitTimeout time.Duration
+	timer        *time.Timer
+	gen          int // bumped on every key so stale timers do nothing
+
+	// count is a number typed in a mode, to repeat the next action.
+	count int
+	// number is a column number being typed a digit at a time for the
+	// numberFor action. It runs once no further digit can name a column,
+	// the digit timeout passes or a key other than a further digit comes.
+	number    int
+	numberFor *actions.Action
+}
+
+// outcome is what a key press did.
+type outcome struct {
+	number  *actions.Call // a column number the key ended, to run first
+	call    *actions.Call // action to run, if any
+	mode    string        // the new mode, when it changed
+	handled bool          // whether the key belonged to mosaico
+}
+
+func newMachine(modes map[string]*Keymap, modeTimeout, chordTimeout time.Duration) *machine {
+	return &machine{
+		modes: modes, mode: DefaultMode,
+		modeTimeout: modeTimeout, chordTimeout: chordTimeout, digitTimeout: digitTimeout,
+	}
+}
+
+// press feeds a key to the machine. Inside a mode or a sequence, keys that
+// match nothing are swallowed; escape leaves the sequence, then the mode.
+func (m *machine) press(c Combo) outcome {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer m.arm()
+
+	km, chord := m.pending, m.pending != nil
+	if !chord {
+		km = m.modes[m.mode]
+	}
+	m.pending = nil
+
+	// Column numbers can take more than one key: "jump-to-column 1" on the
+	// 1 key then the same action on the 2 key is column 12. The 0 key on
+	// its own is column 10.
+	if b, ok := km.bindings[c]; ok {
+		if d, ok := columnDigit(b.Call, c); ok {
+			p := outcome{handled: true}
+			if b.Call.Action != m.numberFor {
+				p.number = m.takeNumber()
+				m.numberFor = b.Call.Action
+			}
+			m.number = m.number*10 + d
+			if m.numberDone() {
+				p.call = m.takeNumber()
+			}
+			return p
+		}
+	}
+	if c == escape && m.numberFor != nil {
+		m.number, m.numberFor = 0, nil
+		return outcome{handled: true}
+	}
+	p := m.feed(km, chord, c)
+	p.number = m.takeNumber()
+	return p
+}
+
+// feed looks a key up in a keymap.
+func (m *machine) feed(km *Keymap, chord bool, c Combo) outcome {
+	if b, ok := km.bindings[c]; ok {
+		if b.Mode != "" {
+			return m.enter(b.Mode)
+		}
+		call := b.Call
+		if m.count > 0 && call.Action.Repeat {
+			call.Count = m.count * max(call.Count, 1)
+		}
+		m.count = 0
+		return outcome{call: &call, handled: true}
+	}
+	if next, ok := km.prefixes[c]; ok {
+		m.pending = next
+		return outcome{handled: true}
+	}
+	if c == escape && !chord && m.mode != DefaultMode {
+		return m.enter(DefaultMode)
+	}
+	if d, ok := digit(c); ok && !chord && m.mode != DefaultMode && c.Modifiers&^ModShift == 0 {
+		m.count = m.count*10 + d
+	}
+	return outcome{handled: chord || m.mode != DefaultMode}
+}
+
+// columnDigit reports the digit typed by a key bound to a column action
+// for that same column, such as "jump-to-column 3" on the 3 key.
+func columnDigit(call actions.Call, c Combo) (int, bool) {
+	a := call.Action
+	d, ok := digit(c)
+	if !ok || len(a.Params) != 1 || a.Params[0].Type != actions.Int ||
+		(call.Args.Int(0) != d && (d != 0 || call.Args.Int(0) != 10)) {
+		return 0, false
+	}
+	return d, true
+}
+
+// numberDone reports whether no further digit can make the column number
+// being typed name a column: 0 alone is column 10, and past a tenth of the
+// column count another digit would only overshoot.
+func (m *machine) numberDone() bool {
+	if m.number == 0 {
+		return true
+	}
+	return columnCount != nil && m.number*10 > columnCount()
+}
+
+// takeNumber returns the call for the column number being typed, if any,
+// and clears it.
+func (m *machine) takeNumber() *actions.Call {
+	if m.numberFor == nil {
+		return nil
+	}
+	n := m.number
+	if n == 0 {
+		n = 10
+	}
+	call := &actions.Call{Action: m.numberFor, Args: actions.Args{n}}
+	m.number, m.numberFor = 0, nil
+	return call
+}
+
+// digit returns the digit the combo's key types on the current layout.
+func digit(c Combo) (int, bool) {
+	for d := range 10 {
+		if code, err := ParseKey(strconv.Itoa(d)); err == nil && code == c.Key {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+func (m *machine) enter(mode string) outcome {
+	m.count = 0
+	p := outcome{handled: true}
+	if mode != m.mode {
+		m.mode = mode
+		p.mode = mode
+	}
+	return p
+}
+
+// arm restarts the timer for the current state: the chord timeout while a
+// sequence is half typed, the digit timeout while a column number is, else
+// the mode timeout outside the default mode.
+func (m *machine) arm() {
+	m.gen++
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	d := m.modeTimeout
+	switch {
+	case m.numberFor != nil:
+		d = m.digitTimeout
+	case m.pending != nil:
+		d = m.chordTimeout
+	case m.mode == DefaultMode:
+		d = 0
+	}
+	if d <= 0 {
+		return
+	}
+	gen := m.gen
+	m.timer = time.AfterFunc(d, func() { m.expire(gen) })
+}
+
+// stop cancels the machine's timer for good and returns the mode it was in.
+func (m *machine) stop() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gen++
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.number, m.numberFor = 0, nil
+	return m.mode
+}
+
+func (m *machine) expire(gen int) {
+	m.mu.Lock()
+	if gen != m.gen {
+		m.mu.Unlock()
+		return
+	}
+	var p outcome
+	switch {
+	case m.pending != nil:
+		m.pending = nil
+	case m.numberFor != nil:
+		p.number = m.takeNumber()
+	default:
+		p = m.enter(DefaultMode)
+	}
+	m.arm()
+	m.mu.Unlock()
+
+	if p.number != nil {
+		runCall(*p.number)
+	}
+	if p.mode != "" {
+		modeChanged(p.mode)
+	}
+}
+
+// Mode returns the active mode.
+func Mode() string {
+	if active == nil {
+		return DefaultMode
+	}
+	return active.keys.Mode()
+}
+
+func (m *machine) Mode() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mode
+}
+
+func modeChanged(mode string) {
+	if onMode != nil {
+		onMode(mode)
+	}
+}
@@ -0,0 +1,163 @@
+package hotkeys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/machina/mosaico/internal/actions"
+	"github.com/machina/mosaico/internal/config"
+)
+
+func testMachine(t *testing.T, modes map[string]map[string]string, chordTimeout time.Duration) *machine {
+	t.Helper()
+	names := map[string]bool{DefaultMode: true}
+	for name := range modes {
+		names[name] = true
+	}
+	defaults, err := DefaultBindings(config.Default().Hotkeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	km, err := ParseBindings(names, false, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keymaps := map[string]*Keymap{DefaultMode: km}
+	for name, table := range modes {
+		if keymaps[name], err = ParseBindings(names, true, table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return newMachine(keymaps, 0, chordTimeout)
+}
+
+func callString(c *actions.Call) string {
+	if c == nil {
+		return ""
+	}
+	return c.String()
+}
+
+func TestColumnNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns int // 0 leaves the column count unknown
+		keys    []string
+		// want is what each key runs: the column number it ended, then
+		// its own action
+		want [][2]string
+	}{
+		{
+			name: "one digit waits for the next key",
+			keys: []string{"ctrl+cmd+alt+1", "ctrl+cmd+alt+l"},
+			want: [][2]string{{"", ""}, {"jump-to-column 1", "scroll-right"}},
+		},
+		{
+			name: "two digits",
+			keys: []string{"ctrl+cmd+alt+1", "ctrl+cmd+alt+2", "ctrl+cmd+alt+l"},
+			want: [][2]string{{"", ""}, {"", ""}, {"jump-to-column 12", "scroll-right"}},
+		},
+		{
+			name: "zero alone is ten, at once",
+			keys: []string{"ctrl+cmd+alt+0", "ctrl+cmd+alt+h"},
+			want: [][2]string{{"", "jump-to-column 10"}, {"", "scroll-left"}},
+		},
+		{
+			name:    "a digit no further digit could extend runs at once",
+			columns: 12,
+			keys:    []string{"ctrl+cmd+alt+3"},
+			want:    [][2]string{{"", "jump-to-column 3"}},
+		},
+		{
+			name:    "a digit that could start a column number waits",
+			columns: 12,
+			keys:    []string{"ctrl+cmd+alt+1", "ctrl+cmd+alt+2"},
+			want:    [][2]string{{"", ""}, {"", "jump-to-column 12"}},
+		},
+		{
+			name:    "a waiting number ends when another action runs at once",
+			columns: 12,
+			keys:    []string{"ctrl+cmd+alt+1", "shift+ctrl+cmd+alt+5"},
+			want:    [][2]string{{"", ""}, {"jump-to-column 1", "move-to-column 5"}},
+		},
+		{
+			name: "another action ends the number",
+			keys: []string{"ctrl+cmd+alt+1", "shift+ctrl+cmd+alt+2", "ctrl+cmd+alt+l"},
+			want: [][2]string{{"", ""}, {"jump-to-column 1", ""}, {"move-to-column 2", "scroll-right"}},
+		},
+		{
+			name: "escape cancels the number",
+			keys: []string{"ctrl+cmd+alt+1", "escape", "ctrl+cmd+alt+l"},
+			want: [][2]string{{"", ""}, {"", ""}, {"", "scroll-right"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.columns > 0 {
+				SetColumnCount(func() int { return tt.columns })
+				defer SetColumnCount(nil)
+			}
+			m := testMachine(t, nil, time.Hour)
+			m.digitTimeout = time.Hour
+			defer m.stop()
+			for i, key := range tt.keys {
+				c, err := ParseCombo(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				p := m.press(c)
+				if got := [2]string{callString(p.number), callString(p.call)}; got != tt.want[i] {
+					t.Errorf("%s: got %q, want %q", key, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestColumnNumberTimeout(t *testing.T) {
+	ran := make(chan actions.Call, 1)
+	SetRunner(func(c actions.Call) { ran <- c })
+	defer SetRunner(nil)
+
+	// The chord timeout is for sequences; digits have their own
+	m := testMachine(t, nil, time.Hour)
+	m.digitTimeout = 20 * time.Millisecond
+	defer m.stop()
+	for _, key := range []string{"shift+ctrl+cmd+alt+1", "shift+ctrl+cmd+alt+5"} {
+		c, _ := ParseCombo(key)
+		if p := m.press(c); p.number != nil || p.call != nil {
+			t.Fatalf("%s ran %s %s before the timeout", key, callString(p.number), callString(p.call))
+		}
+	}
+	select {
+	case c := <-ran:
+		if c.String() != "move-to-column 15" {
+			t.Errorf("got %s, want move-to-column 15", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the timeout ran nothing")
+	}
+}
+
+func TestModeCount(t *testing.T) {
+	m := testMachine(t, map[string]map[string]string{"nav": {"l": "scroll-right", "c": "close-window"}}, time.Hour)
+	defer m.stop()
+	m.enter("nav")
+	for _, tt := range []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"1", "2", "l"}, "12 scroll-right"},
+		{[]string{"4", "c"}, "close-window"},
+		{[]string{"l"}, "scroll-right"},
+	} {
+		var p outcome
+		for _, key := range tt.keys {
+			c, _ := ParseCombo(key)
+			p = m.press(c)
+		}
+		if got := callString(p.call); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package hotkeys
+
+import (
+	"fmt"
+	"sync"
+)
+
+// AppPolicy is what mosaico does with hotkeys while an app is frontmost.
+type AppPolicy int
+
+const (
+	// PolicyConsume runs matching hotkeys and keeps them from the app.
+	PolicyConsume AppPolicy = iota
+	// PolicyShare runs matching hotkeys and passes them on to the app too.
+	PolicyShare
+	// PolicyIgnore leaves every key to the app, for instance a virtual
+	// machine or remote desktop that has its own window manager.
+	PolicyIgnore
+)
+
+func (p AppPolicy) String() string {
+	switch p {
+	case PolicyConsume:
+		return "consume"
+	case PolicyShare:
+		return "share"
+	case PolicyIgnore:
+		return "ignore"
+	}
+	return fmt.Sprintf("AppPolicy(%d)", int(p))
+}
+
+// ParseAppPolicy parses a policy name. An empty name is PolicyConsume.
+func ParseAppPolicy(s string) (AppPolicy, error) {
+	switch s {
+	case "", "consume":
+		return PolicyConsume, nil
+	case "share":
+		return PolicyShare, nil
+	case "ignore":
+		return PolicyIgnore, nil
+	}
+	return PolicyConsume, fmt.Errorf("unknown hotkeys policy %q (want consume, share or ignore)", s)
+}
+
+// Decide reports whether a key event should be kept from the frontmost
+// app, given its policy and whether the key belonged to mosaico.
+func Decide(policy AppPolicy, handled bool) bool {
+	return handled && policy == PolicyConsume
+}
+
+// keyFilter decides which key events the event tap keeps from the
+// frontmost app. A key-up is kept exactly when its key-down was, and so
+// are the autorepeats in between, even if another app came to the front
+// meanwhile. It runs nothing itself, so sequences of events can be tested
+// without CoreGraphics.
+type keyFilter struct {
+	mu        sync.Mutex
+	policies  map[string]AppPolicy // apps whose policy isn't PolicyConsume, by bundle ID
+	swallowed map[int]bool         // keycodes whose key-down was kept from the app
+}
+
+var filter = keyFilter{swallowed: make(map[int]bool)}
+
+// configure sets the per-app policies. Keys held down stay paired.
+func (f *keyFilter) configure(policies map[string]AppPolicy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.policies = policies
+}
+
+// perApp reports whether any app has a policy of its own, that is
+// whether down needs the frontmost app's bundle ID.
+func (f *keyFilter) perApp() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.policies) > 0
+}
+
+// down handles a key-down event, or an autorepeat one, while the app with
+// bundle ID front is frontmost, and reports whether to swallow it. handle
+// feeds the event to mosaico and reports whether the key belonged to it;
+// it isn't called for apps with PolicyIgnore.
+func (f *keyFilter) down(key int, front string, repeat bool, handle func() bool) bool {
+	f.mu.Lock()
+	policy := f.policies[front]
+	f.mu.Unlock()
+	handled := policy != PolicyIgnore && handle()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if repeat {
+		return f.swallowed[key]
+	}
+	consume := Decide(policy, handled)
+	if consume {
+		f.swallowed[key] = true
+	} else {
+		delete(f.swallowed, key)
+	}
+	return consume
+}
+
+// up handles a key-up event and reports whether to swallow it.
+func (f *keyFilter) up(key int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.swallowed[key] {
+		return false
+	}
+	delete(f.swallowed, key)
+	return true
+}
+
+// keyDown handles a key-down event while the app with bundle ID front is
+// frontmost, and reports whether to swallow it. Autorepeat events of a held
+// hotkey go to the repeater instead of the keymaps.
+func (h *hotkeys) keyDown(keyCode, modifiers int, front string, repeat bool) bool {
+	return filter.down(keyCode, front, repeat, func() bool {
+		if repeat {
+			repeats.autorepeat(keyCode)
+			return false
+		}
+		return h.handleKey(keyCode, modifiers)
+	})
+}
+
+// keyUp stops any repeat of the key and reports whether to swallow the
+// event.
+func keyUp(keyCode int) bool {
+	repeats.release(keyCode)
+	return filter.up(keyCode)
+}
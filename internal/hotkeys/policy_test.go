@@ -0,0 +1,121 @@
+package hotkeys
+
+import "testing"
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		policy  AppPolicy
+		handled bool
+		want    bool
+	}{
+		{PolicyConsume, true, true},
+		{PolicyConsume, false, false},
+		{PolicyShare, true, false},
+		{PolicyShare, false, false},
+		{PolicyIgnore, true, false},
+		{PolicyIgnore, false, false},
+	}
+	for _, tt := range tests {
+		if got := Decide(tt.policy, tt.handled); got != tt.want {
+			t.Errorf("Decide(%s, %v) = %v, want %v", tt.policy, tt.handled, got, tt.want)
+		}
+	}
+}
+
+func TestKeyFilter(t *testing.T) {
+	const (
+		down = iota
+		repeat
+		up
+	)
+	type event struct {
+		kind    int
+		key     int
+		front   string
+		handled bool // what the keymaps make of it
+		swallow bool
+	}
+	policies := map[string]AppPolicy{
+		"com.example.vm":     PolicyIgnore,
+		"com.example.editor": PolicyShare,
+	}
+	tests := []struct {
+		name    string
+		events  []event
+		handles int // how often the keymaps see an event
+	}{
+		{"hotkey is kept from the app, up and all", []event{
+			{kind: down, key: 4, front: "com.example.app", handled: true, swallow: true},
+			{kind: repeat, key: 4, front: "com.example.app", swallow: true},
+			{kind: repeat, key: 4, front: "com.example.app", swallow: true},
+			{kind: up, key: 4, swallow: true},
+		}, 3},
+		{"other keys pass through", []event{
+			{kind: down, key: 0, front: "com.example.app"},
+			{kind: repeat, key: 0, front: "com.example.app"},
+			{kind: up, key: 0},
+		}, 2},
+		{"no app in front", []event{
+			{kind: down, key: 4, handled: true, swallow: true},
+			{kind: up, key: 4, swallow: true},
+		}, 1},
+		{"shared with the app", []event{
+			{kind: down, key: 4, front: "com.example.editor", handled: true},
+			{kind: repeat, key: 4, front: "com.example.editor"},
+			{kind: up, key: 4},
+		}, 2},
+		{"ignored app gets every key", []event{
+			{kind: down, key: 4, front: "com.example.vm", handled: true},
+			{kind: repeat, key: 4, front: "com.example.vm"},
+			{kind: up, key: 4},
+		}, 0},
+		{"app switched while the key is held", []event{
+			{kind: down, key: 4, front: "com.example.app", handled: true, swallow: true},
+			{kind: repeat, key: 4, front: "com.example.vm", swallow: true},
+			{kind: up, key: 4, swallow: true},
+		}, 1},
+		{"held through a switch to a consuming app", []event{
+			{kind: down, key: 4, front: "com.example.vm", handled: true},
+			{kind: repeat, key: 4, front: "com.example.app"},
+			{kind: up, key: 4},
+		}, 1},
+		{"keys pair independently", []event{
+			{kind: down, key: 4, front: "com.example.app", handled: true, swallow: true},
+			{kind: down, key: 0, front: "com.example.app"},
+			{kind: up, key: 0},
+			{kind: up, key: 4, swallow: true},
+		}, 2},
+		{"a later press of the same key isn't kept", []event{
+			{kind: down, key: 4, front: "com.example.app", handled: true, swallow: true},
+			{kind: down, key: 4, front: "com.example.app"},
+			{kind: up, key: 4},
+		}, 2},
+		{"up without a down passes", []event{
+			{kind: up, key: 4},
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := keyFilter{swallowed: make(map[int]bool)}
+			f.configure(policies)
+			handles := 0
+			for i, e := range tt.events {
+				var got bool
+				if e.kind == up {
+					got = f.up(e.key)
+				} else {
+					got = f.down(e.key, e.front, e.kind == repeat, func() bool {
+						handles++
+						return e.handled
+					})
+				}
+				if got != e.swallow {
+					t.Errorf("event %d: swallowed %v, want %v", i, got, e.swallow)
+				}
+			}
+			if handles != tt.handles {
+				t.Errorf("keymaps saw %d events, want %d", handles, tt.handles)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package hotkeys
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/machina/mosaico/internal/actions"
+)
+
+// RepeatMode is what holding down a hotkey does.
+type RepeatMode int
+
+const (
+	// RepeatAccelerate runs the action at repeat_rate once the key starts
+	// repeating, speeding up to repeat_max_rate over repeat_ramp seconds.
+	RepeatAccelerate RepeatMode = iota
+	// RepeatOS runs the action on every autorepeat event, at the system's
+	// key repeat rate.
+	RepeatOS
+	// RepeatOff runs the action once per press.
+	RepeatOff
+)
+
+func (m RepeatMode) String() string {
+	switch m {
+	case RepeatAccelerate:
+		return "accelerate"
+	case RepeatOS:
+		return "os"
+	case RepeatOff:
+		return "off"
+	}
+	return fmt.Sprintf("RepeatMode(%d)", int(m))
+}
+
+// ParseRepeatMode parses a repeat mode name. An empty name is
+// RepeatAccelerate.
+func ParseRepeatMode(s string) (RepeatMode, error) {
+	switch s {
+	case "", "accelerate":
+		return RepeatAccelerate, nil
+	case "os":
+		return RepeatOS, nil
+	case "off":
+		return RepeatOff, nil
+	}
+	return RepeatAccelerate, fmt.Errorf("unknown repeat mode %q (want accelerate, os or off)", s)
+}
+
+// Repeat configures held hotkeys. Rates are actions per second.
+type Repeat struct {
+	Mode    RepeatMode
+	Rate    float64
+	MaxRate float64
+	Ramp    time.Duration
+}
+
+// Interval returns the time between actions once a key has been held for
+// held: 1/Rate at first, shrinking linearly to 1/MaxRate after Ramp.
+func (r Repeat) Interval(held time.Duration) time.Duration {
+	rate := max(r.Rate, 1)
+	if r.MaxRate > rate && r.Ramp > 0 {
+		rate += (r.MaxRate - rate) * min(float64(held)/float64(r.Ramp), 1)
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// repeater turns a held key into a stream of actions. Key events come from
+// the event tap; its timer runs on its own goroutine.
+type repeater struct {
+	mu     sync.Mutex
+	config Repeat
+	key    int
+	call   *actions.Call // the held key's action, if it repeats
+	start  time.Time
+	timer  *time.Timer
+	gen    int // bumped on stop so a timer already firing does nothing
+}
+
+var repeats repeater
+
+// hold starts tracking a key that was just pressed, replacing any other:
+// the system only repeats the last key pressed.
+func (r *repeater) hold(key int, call *actions.Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopLocked()
+	if call != nil && !call.Action.Repeat {
+		call = nil
+	}
+	r.key, r.call = key, call
+}
+
+// autorepeat handles an autorepeat event for key.
+func (r *repeater) autorepeat(key int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.call == nil || r.key != key {
+		return
+	}
+	switch r.config.Mode {
+	case RepeatAccelerate:
+		// The first autorepeat event starts our own, smoother stream; the
+		// rest are dropped.
+		if r.timer == nil {
+			r.start = time.Now()
+			r.tickLocked()
+		}
+	case RepeatOS:
+		runTick(*r.call, r.gen)
+	case RepeatOff:
+	}
+}
+
+// release stops repeating when the held key goes up.
+func (r *repeater) release(key int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.key == key {
+		r.stopLocked()
+		r.call = nil
+	}
+}
+
+func (r *repeater) tickLocked() {
+	gen := r.gen
+	runTick(*r.call, gen)
+	r.timer = time.AfterFunc(r.config.Interval(time.Since(r.start)), func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.gen == gen && r.call != nil {
+			r.tickLocked()
+		}
+	})
+}
+
+// live reports whether the key held at gen is still held.
+func (r *repeater) live(gen int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.gen == gen && r.call != nil
+}
+
+func (r *repeater) stopLocked() {
+	r.gen++
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+func (r *repeater) configure(c Repeat) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopLocked()
+	r.call = nil
+	r.config = c
+}
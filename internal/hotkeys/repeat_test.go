@@ -0,0 +1,84 @@
+package hotkeys
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/machina/mosaico/internal/actions"
+)
+
+func TestInterval(t *testing.T) {
+	tests := []struct {
+		name   string
+		repeat Repeat
+		held   time.Duration
+		want   time.Duration
+	}{
+		{"start", Repeat{Rate: 8, MaxRate: 32, Ramp: time.Second}, 0, time.Second / 8},
+		{"halfway", Repeat{Rate: 8, MaxRate: 32, Ramp: time.Second}, time.Second / 2, time.Second / 20},
+		{"ramped", Repeat{Rate: 8, MaxRate: 32, Ramp: time.Second}, time.Second, time.Second / 32},
+		{"past the ramp", Repeat{Rate: 8, MaxRate: 32, Ramp: time.Second}, time.Minute, time.Second / 32},
+		{"no ramp", Repeat{Rate: 8, MaxRate: 32}, time.Minute, time.Second / 8},
+		{"max below rate", Repeat{Rate: 8, MaxRate: 4, Ramp: time.Second}, time.Minute, time.Second / 8},
+		{"rate below 1", Repeat{Rate: 0}, 0, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.repeat.Interval(tt.held); got != tt.want {
+				t.Errorf("Interval(%v) = %v, want %v", tt.held, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatTicks(t *testing.T) {
+	tests := []struct {
+		name    string
+		release bool
+		want    []string
+	}{
+		{"ticks queue one at a time", false, []string{"scroll-right", "scroll-right", "done"}},
+		{"a queued tick is skipped after release", true, []string{"scroll-right", "done"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := make(chan actions.Call, 10)
+			block := make(chan struct{})
+			SetRunner(func(c actions.Call) {
+				ran <- c
+				if c.Action.Name == "scroll-right" {
+					<-block
+				}
+			})
+			defer SetRunner(nil)
+			repeats.configure(Repeat{Mode: RepeatOS})
+			defer repeats.configure(Repeat{})
+
+			call := actions.Call{Action: actions.Lookup("scroll-right")}
+			repeats.hold(1, &call)
+			runCall(call)
+			<-ran // the press is running and blocks the worker
+			for range 3 {
+				repeats.autorepeat(1)
+			}
+			if tt.release {
+				repeats.release(1)
+			}
+			close(block)
+			runCall(actions.Call{Action: actions.Lookup("quit-app")})
+
+			got := []string{"scroll-right"}
+			for c := range ran {
+				if c.Action.Name != "scroll-right" {
+					got = append(got, "done")
+					break
+				}
+				got = append(got, c.Action.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ran %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package hotkeys
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/machina/mosaico/internal/actions"
+)
+
+// columnDistance is how far, in points, a trackpad swipe travels per column
+// at sensitivity 1.
+const columnDistance = 80
+
+// ScrollMode is what scrolling the strip does.
+type ScrollMode int
+
+const (
+	// ScrollColumns moves focus a column at a time, like scroll-left and
+	// scroll-right.
+	ScrollColumns ScrollMode = iota
+	// ScrollPixels pans the viewport smoothly.
+	ScrollPixels
+	// ScrollOff leaves scroll events to the apps.
+	ScrollOff
+)
+
+func (m ScrollMode) String() string {
+	switch m {
+	case ScrollColumns:
+		return "column"
+	case ScrollPixels:
+		return "pixel"
+	case ScrollOff:
+		return "off"
+	}
+	return fmt.Sprintf("ScrollMode(%d)", int(m))
+}
+
+// ParseScrollMode parses a scroll mode name. An empty name is ScrollColumns.
+func ParseScrollMode(s string) (ScrollMode, error) {
+	switch s {
+	case "", "column":
+		return ScrollColumns, nil
+	case "pixel":
+		return ScrollPixels, nil
+	case "off":
+		return ScrollOff, nil
+	}
+	return ScrollColumns, fmt.Errorf("unknown scroll mode %q (want column, pixel or off)", s)
+}
+
+// ScrollEvent is a scroll wheel or trackpad event. Deltas are in points,
+// positive when the content moves right or down, as macOS reports them.
+type ScrollEvent struct {
+	DX, DY float64
+	// Continuous is set for trackpads and other devices without notches.
+	Continuous bool
+	// Momentum is set for the inertia events after the fingers lift.
+	Momentum bool
+	// Began marks the start of a gesture. Ended marks the end of the
+	// fingers' part of it, then of its momentum.
+	Began, Ended bool
+}
+
+// Scroller turns scroll events into viewport movement.
+type Scroller struct {
+	Mode        ScrollMode
+	Modifiers   int  // modifiers that make any scroll drive the strip
+	Swipe       bool // also take bare horizontal trackpad swipes
+	Sensitivity float64
+	Invert      bool
+
+	swiping bool    // a gesture is being taken
+	x       bool    // the gesture follows the horizontal axis
+	acc     float64 // points scrolled towards the next column
+}
+
+// Add feeds an event with the modifiers held, and reports whether the
+// strip takes it. Taken events scroll by columns (positive is right) in
+// column mode, or by pixels in pixel mode.
+func (s *Scroller) Add(e ScrollEvent, modifiers int) (columns int, pixels float64, taken bool) {
+	if s.Mode == ScrollOff {
+		return 0, 0, false
+	}
+	horizontal := math.Abs(e.DX) > math.Abs(e.DY)
+	if e.Began {
+		s.swiping = false
+	}
+	switch {
+	case s.swiping:
+	case modifiers&modMask == s.Modifiers && s.Modifiers != 0:
+	case s.Swipe && e.Continuous && !e.Momentum && horizontal && modifiers&modMask == 0:
+	default:
+		return 0, 0, false
+	}
+	if !s.swiping {
+		s.x, s.acc = horizontal, 0
+	}
+	// A gesture is over once its momentum ends; without momentum, the
+	// next gesture's Began is what ends it
+	s.swiping = e.Continuous && !(e.Momentum && e.Ended)
+
+	delta := e.DY
+	if s.x {
+		delta = e.DX
+	}
+	// Content moving right reveals what is left of it
+	sensitivity := s.Sensitivity
+	if sensitivity <= 0 {
+		sensitivity = 1
+	}
+	delta = -delta * sensitivity
+	if s.Invert {
+		delta = -delta
+	}
+
+	switch s.Mode {
+	case ScrollPixels:
+		return 0, delta, true
+	case ScrollColumns:
+		if !e.Continuous {
+			return int(sign(delta)), 0, true
+		}
+		if e.Momentum {
+			// Inertia would overshoot; the gesture already decided
+			return 0, 0, true
+		}
+		s.acc += delta
+		columns = int(s.acc / columnDistance)
+		s.acc -= float64(columns) * columnDistance
+		return columns, 0, true
+	case ScrollOff:
+	}
+	return 0, 0, false
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+// scrolled handles a scroll event from the event tap, and reports whether
+// to swallow it.
+func (h *hotkeys) scrolled(e ScrollEvent, modifiers int) bool {
+	columns, pixels, taken := h.scroller.Add(e, modifiers)
+	if !taken {
+		return false
+	}
+	name := "scroll-right"
+	if columns < 0 {
+		name, columns = "scroll-left", -columns
+	}
+	if columns > 0 {
+		runCall(actions.Call{Action: actions.Lookup(name), Count: columns})
+	}
+	if pixels != 0 {
+		runCall(actions.Call{Action: actions.Lookup("scroll-by"), Args: actions.Args{pixels}})
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package hotkeys
+
+import (
+	"slices"
+	"testing"
+)
+
+// swipe is a trackpad event moving the content dx points right.
+func swipe(dx float64) ScrollEvent {
+	return ScrollEvent{DX: dx, Continuous: true}
+}
+
+func momentum(dx float64) ScrollEvent {
+	return ScrollEvent{DX: dx, Continuous: true, Momentum: true}
+}
+
+func TestScroller(t *testing.T) {
+	type step struct {
+		e         ScrollEvent
+		modifiers int
+	}
+	// result is what Add returned for one step: columns, or pixels in
+	// pixel mode, or -99 when the event wasn't taken
+	tests := []struct {
+		name     string
+		scroller Scroller
+		steps    []step
+		want     []float64
+	}{
+		{
+			name:     "swipe adds up to whole columns",
+			scroller: Scroller{Swipe: true},
+			steps:    []step{{e: swipe(-30)}, {e: swipe(-30)}, {e: swipe(-30)}, {e: swipe(-70)}, {e: swipe(-100)}},
+			want:     []float64{0, 0, 1, 1, 1},
+		},
+		{
+			name:     "swipe left",
+			scroller: Scroller{Swipe: true},
+			steps:    []step{{e: swipe(50)}, {e: swipe(50)}, {e: swipe(200)}},
+			want:     []float64{0, -1, -2},
+		},
+		{
+			name:     "momentum is taken but doesn't scroll",
+			scroller: Scroller{Swipe: true},
+			steps: []step{
+				{e: swipe(-100)},
+				{e: momentum(-300)},
+				{e: momentum(-300)},
+				{e: ScrollEvent{DX: -10, Continuous: true, Momentum: true, Ended: true}},
+			},
+			want: []float64{1, 0, 0, 0},
+		},
+		{
+			name:     "momentum alone is left to the app",
+			scroller: Scroller{Swipe: true},
+			steps:    []step{{e: momentum(-300)}},
+			want:     []float64{-99},
+		},
+		{
+			name:     "a new gesture starts from zero",
+			scroller: Scroller{Swipe: true},
+			steps:    []step{{e: swipe(-60)}, {e: ScrollEvent{DX: -60, Continuous: true, Began: true}}},
+			want:     []float64{0, 0},
+		},
+		{
+			name:     "sensitivity and invert",
+			scroller: Scroller{Swipe: true, Sensitivity: 2, Invert: true},
+			steps:    []step{{e: swipe(-40)}, {e: swipe(-40)}},
+			want:     []float64{-1, -1},
+		},
+		{
+			name:     "vertical swipes are left to the app",
+			scroller: Scroller{Swipe: true},
+			steps:    []step{{e: ScrollEvent{DY: -100, Continuous: true}}},
+			want:     []float64{-99},
+		},
+		{
+			name:     "bare swipes are left to the app unless enabled",
+			scroller: Scroller{Modifiers: ModCtrl},
+			steps:    []step{{e: swipe(-100)}, {e: swipe(-100), modifiers: ModCtrl}},
+			want:     []float64{-99, 1},
+		},
+		{
+			name:     "wheel notches scroll a column each",
+			scroller: Scroller{Modifiers: ModCtrl},
+			steps:    []step{{e: ScrollEvent{DY: -1}, modifiers: ModCtrl}, {e: ScrollEvent{DY: 3}, modifiers: ModCtrl}},
+			want:     []float64{1, -1},
+		},
+		{
+			name:     "pixel mode",
+			scroller: Scroller{Mode: ScrollPixels, Swipe: true},
+			steps:    []step{{e: swipe(-30)}, {e: momentum(-20)}},
+			want:     []float64{30, 20},
+		},
+		{
+			name:     "off",
+			scroller: Scroller{Mode: ScrollOff, Swipe: true},
+			steps:    []step{{e: swipe(-100)}},
+			want:     []float64{-99},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.scroller
+			var got []float64
+			for _, st := range tt.steps {
+				columns, pixels, taken := s.Add(st.e, st.modifiers)
+				switch {
+				case !taken:
+					got = append(got, -99)
+				case s.Mode == ScrollPixels:
+					got = append(got, pixels)
+				default:
+					got = append(got, float64(columns))
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
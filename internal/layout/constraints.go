@@ -0,0 +1,126 @@
+package layout
+
+import (
+	"math"
+
+	"github.com/machina/mosaico/internal/strip"
+)
+
+// tolerance is how far an actual frame may drift from the requested one
+// before it counts as refused.
+const tolerance = 1.0
+
+type Size struct {
+	Width  float64
+	Height float64
+}
+
+// Sizes caches the smallest sizes apps have been seen to accept, keyed by
+// bundle ID.
+type Sizes struct {
+	min map[string]Size
+}
+
+func NewSizes() *Sizes {
+	return &Sizes{min: make(map[string]Size)}
+}
+
+func (c *Sizes) Min(bundleID string) Size {
+	return c.min[bundleID]
+}
+
+// Learn records a minimum size when an app refused to shrink to the
+// requested frame. It reports whether the cached minimum grew.
+func (c *Sizes) Learn(bundleID string, requested, actual Rect) bool {
+	if bundleID == "" {
+		return false
+	}
+	m := c.min[bundleID]
+	grew := false
+	if actual.Width > requested.Width+tolerance && actual.Width > m.Width {
+		m.Width = actual.Width
+		grew = true
+	}
+	if actual.Height > requested.Height+tolerance && actual.Height > m.Height {
+		m.Height = actual.Height
+		grew = true
+	}
+	if grew {
+		c.min[bundleID] = m
+	}
+	return grew
+}
+
+// ApplyMinimums widens columns holding windows whose app is known not to
+// shrink to the column width. It reports whether any column changed.
+func ApplyMinimums(s *strip.Strip, sizes *Sizes, gap float64) bool {
+	changed := false
+	for i, col := range s.Columns {
+		need := float64(0)
+		for _, win := range col.Windows {
+			need = max(need, sizes.Min(win.BundleID).Width+gap)
+		}
+		if need > s.ColumnWidth(i)+tolerance {
+			col.Fit = need
+			changed = true
+		}
+	}
+	return changed
+}
+
+type FixKind int
+
+const (
+	// FixNone means the window took the requested frame.
+	FixNone FixKind = iota
+	// FixWiden means the window is wider than its slot and the column must
+	// grow to ColumnWidth.
+	FixWiden
+	// FixRecenter means the window should move to X, Y: it is smaller
+	// than its slot (e.g. a terminal snapped to character cells), or too
+	// wide for a slot clipped by the screen edge.
+	FixRecenter
+)
+
+// Fix is how the layout adapts to a window that refused its frame.
+type Fix struct {
+	Kind        FixKind
+	ColumnWidth float64
+	X           float64
+	Y           float64
+}
+
+// Reconcile compares the frame a window ended up with against its
+// placement and decides how to adapt.
+func Reconcile(p Placement, actual Rect, gap float64) Fix {
+	want := p.Frame
+	if actual.Width > want.Width+tolerance && p.Visible && p.Side != 0 {
+		// Clipped by the screen edge, so a wider column won't help; keep
+		// the window from crossing that edge instead
+		x := want.X
+		if p.Side > 0 {
+			x = want.X + want.Width - actual.Width
+		}
+		if math.Abs(actual.X-x) <= tolerance && math.Abs(actual.Y-want.Y) <= tolerance {
+			return Fix{Kind: FixNone}
+		}
+		return Fix{Kind: FixRecenter, X: x, Y: want.Y}
+	}
+	if actual.Width > want.Width+tolerance {
+		return Fix{Kind: FixWiden, ColumnWidth: actual.Width + gap}
+	}
+
+	smaller := actual.Width < want.Width-tolerance || actual.Height < want.Height-tolerance
+	moved := math.Abs(actual.X-want.X) > tolerance || math.Abs(actual.Y-want.Y) > tolerance
+	if !smaller && !moved {
+		return Fix{Kind: FixNone}
+	}
+
+	// Center inside the slot; a window taller than its slot stays at the top
+	x := want.X + (want.Width-actual.Width)/2
+	y := want.Y + max(want.Height-actual.Height, 0)/2
+	if math.Abs(actual.X-x) <= tolerance && math.Abs(actual.Y-y) <= tolerance {
+		return Fix{Kind: FixNone}
+	}
+	return Fix{Kind: FixRecenter, X: x, Y: y}
+}
@@ -0,0 +1,93 @@
+package layout
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/machina/mosaico/internal/strip"
+)
+
+// DragPolicy is what happens to a tiled window dragged with the mouse.
+type DragPolicy string
+
+const (
+	// DragSnap puts the window back where it was.
+	DragSnap DragPolicy = "snap"
+	// DragReorder moves the window to where it was dropped.
+	DragReorder DragPolicy = "reorder"
+	// DragFloat takes the window out of the strip.
+	DragFloat DragPolicy = "float"
+)
+
+func ParseDragPolicy(s string) (DragPolicy, error) {
+	switch p := DragPolicy(s); p {
+	case DragSnap, DragReorder, DragFloat:
+		return p, nil
+	case "":
+		return DragSnap, nil
+	}
+	return "", fmt.Errorf("unknown drag policy %q (want snap, reorder or float)", s)
+}
+
+// Moved reports whether a window is no longer at the frame it was given.
+func Moved(expected, actual Rect) bool {
+	return math.Abs(expected.X-actual.X) > tolerance || math.Abs(expected.Y-actual.Y) > tolerance
+}
+
+// Resized reports whether a window is no longer the size it was given.
+func Resized(expected, actual Rect) bool {
+	return math.Abs(expected.Width-actual.Width) > tolerance || math.Abs(expected.Height-actual.Height) > tolerance
+}
+
+// Drop is where a dragged window lands in a strip: stacked into Stack at
+// Row, or, when Stack is nil, in a new column in front of Before (at the
+// end when Before is nil too).
+type Drop struct {
+	Before *strip.Column
+	Stack  *strip.Column
+	Row    int
+}
+
+// DropTarget finds where the window with the given ID goes when dropped at
+// x, y on the strip's screen. The outer thirds of a column insert a new
+// column on that side; the middle third stacks into it. It returns false
+// when the window was dropped back onto its own column.
+func DropTarget(s *strip.Strip, screen Rect, id uint32, x, y float64) (Drop, bool) {
+	if len(s.Columns) == 0 {
+		return Drop{}, true
+	}
+	// alone is set for a column holding only the dragged window; inserting
+	// next to it leaves everything as it was
+	alone := func(col *strip.Column) bool {
+		return len(col.Windows) == 1 && col.Windows[0].ID == id
+	}
+	sx := x - screen.X + s.ViewportOffset
+	if sx < 0 {
+		return Drop{Before: s.Columns[0]}, !alone(s.Columns[0])
+	}
+	for i, col := range s.Columns {
+		left, w := s.ColumnX(i), s.ColumnWidth(i)
+		if sx >= left+w {
+			continue
+		}
+		if alone(col) {
+			return Drop{}, false
+		}
+
+		switch rel := (sx - left) / w; {
+		case rel < 1.0/3:
+			return Drop{Before: col}, true
+		case rel > 2.0/3:
+			if i+1 < len(s.Columns) {
+				next := s.Columns[i+1]
+				return Drop{Before: next}, !alone(next)
+			}
+			return Drop{}, true
+		}
+		// Nearest boundary between the stacked windows
+		rowHeight := screen.Height / float64(len(col.Windows))
+		row := int(math.Round((y - screen.Y) / rowHeight))
+		return Drop{Stack: col, Row: max(0, min(row, len(col.Windows)))}, true
+	}
+	return Drop{}, !alone(s.Columns[len(s.Columns)-1])
+}
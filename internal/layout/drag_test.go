@@ -0,0 +1,67 @@
+package layout
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/machina/mosaico/internal/strip"
+)
+
+func TestDropTarget(t *testing.T) {
+	// Three 1000-wide columns on a 2000-wide screen at x 100; the middle
+	// one holds windows 2 and 3
+	screen := Rect{X: 100, Width: 2000, Height: 1000}
+	newStrip := func(offset float64) *strip.Strip {
+		s := strip.New()
+		s.SetViewportWidth(screen.Width)
+		s.Columns = []*strip.Column{
+			{Windows: []*strip.Window{{ID: 1}}},
+			{Windows: []*strip.Window{{ID: 2}, {ID: 3}}},
+			{Windows: []*strip.Window{{ID: 4}}},
+		}
+		s.ViewportOffset = offset
+		return s
+	}
+	// before and stack are column indexes, -1 for none
+	tests := []struct {
+		name   string
+		offset float64
+		id     uint32
+		x, y   float64
+		before int
+		stack  int
+		row    int
+		ok     bool
+	}{
+		{name: "before the strip", id: 4, x: 50, before: 0, stack: -1, ok: true},
+		{name: "own column before the strip", id: 1, x: 50, before: 0, stack: -1},
+		{name: "left third", id: 4, x: 1200, before: 1, stack: -1, ok: true},
+		{name: "right third", id: 4, x: 1000, before: 1, stack: -1, ok: true},
+		{name: "right third next to own column", id: 4, x: 2000, before: 2, stack: -1},
+		{name: "own column", id: 1, x: 600, before: -1, stack: -1},
+		{name: "stack on top", id: 4, x: 1600, y: 100, before: -1, stack: 1, row: 0, ok: true},
+		{name: "stack in between", id: 4, x: 1600, y: 450, before: -1, stack: 1, row: 1, ok: true},
+		{name: "stack at the bottom", id: 4, x: 1600, y: 990, before: -1, stack: 1, row: 2, ok: true},
+		{name: "restack in own column", id: 2, x: 1600, y: 990, before: -1, stack: 1, row: 2, ok: true},
+		{name: "right third of the last column", offset: 1000, id: 1, x: 2000, before: -1, stack: -1, ok: true},
+		{name: "past the end", offset: 2000, id: 1, x: 1500, before: -1, stack: -1, ok: true},
+		{name: "own column past the end", offset: 2000, id: 4, x: 1500, before: -1, stack: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStrip(tt.offset)
+			drop, ok := DropTarget(s, screen, tt.id, tt.x, tt.y)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			before, stack := slices.Index(s.Columns, drop.Before), slices.Index(s.Columns, drop.Stack)
+			if before != tt.before || stack != tt.stack || drop.Row != tt.row {
+				t.Errorf("got before %d, stack %d, row %d; want before %d, stack %d, row %d",
+					before, stack, drop.Row, tt.before, tt.stack, tt.row)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/machina/mosaico/internal/strip"
+	"github.com/machina/mosaico/internal/wm"
+)
+
+// Screen is the frame a display's strip is laid out on.
+type Screen struct {
+	DisplayID uint32 `json:"display_id"`
+	Frame     Rect   `json:"frame"`
+}
+
+// State is a snapshot of the strips and the screens they are laid out on,
+// enough to recompute the layout anywhere.
+type State struct {
+	Displays *strip.Displays `json:"displays"`
+	Screens  []Screen        `json:"screens"`
+	Gap      float64         `json:"gap"`
+	// Failures lists windows the backend keeps failing to manage.
+	Failures []wm.Failure `json:"failures,omitempty"`
+}
+
+// StatePath is where the daemon saves its latest state.
+func StatePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "mosaico", "state.json")
+}
+
+func SaveState(path string, st State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func LoadState(path string) (State, error) {
+	var st State
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if st.Displays == nil {
+		st.Displays = strip.NewDisplays()
+	}
+	return st, nil
+}
+
+// Entry is one row of a dry run: where a window would go and whether it
+// would be shown.
+type Entry struct {
+	DisplayID uint32  `json:"display_id"`
+	Column    int     `json:"column"`
+	Row       int     `json:"row"`
+	WindowID  uint32  `json:"window_id"`
+	PID       uint32  `json:"pid"`
+	BundleID  string  `json:"bundle_id,omitempty"`
+	AppName   string  `json:"app_name,omitempty"`
+	Title     string  `json:"title"`
+	Frame     Rect    `json:"frame"`
+	Visible   bool    `json:"visible"`
+	Parking   Parking `json:"parking,omitempty"`
+}
+
+// DryRun computes the layout of every strip without touching any window or
+// strip. Columns are widened for the minimum sizes in sizes, if any, like
+// the layout does. Strips without a screen are skipped.
+func DryRun(st State, sizes *Sizes, strategyFor func(p Placement) Parking) []Entry {
+	screens := make(map[uint32]Rect)
+	for _, sc := range st.Screens {
+		screens[sc.DisplayID] = sc.Frame
+	}
+
+	var placements []Placement
+	displayOf := make(map[uint32]uint32)
+	for _, s := range st.Displays.Strips {
+		screen, ok := screens[s.DisplayID]
+		if !ok {
+			continue
+		}
+		s = s.Clone()
+		s.SetViewportWidth(screen.Width)
+		if sizes != nil && ApplyMinimums(s, sizes, st.Gap) {
+			s.ScrollToColumn(s.FocusedCol)
+		}
+		for _, p := range Compute(s, screen, st.Gap) {
+			placements = append(placements, p)
+			displayOf[p.Window.ID] = s.DisplayID
+		}
+	}
+
+	plan := ResolveParking(placements, strategyFor)
+	hidden := make(map[uint32]bool)
+	for _, pid := range plan.HideApps {
+		hidden[pid] = true
+	}
+
+	entries := make([]Entry, 0, len(placements))
+	for _, p := range placements {
+		e := Entry{
+			DisplayID: displayOf[p.Window.ID],
+			Column:    p.Column,
+			Row:       p.Row,
+			WindowID:  p.Window.ID,
+			PID:       p.Window.PID,
+			BundleID:  p.Window.BundleID,
+			AppName:   p.Window.AppName,
+			Title:     p.Window.Title,
+			Frame:     p.Frame,
+			Visible:   p.Visible,
+		}
+		if !p.Visible {
+			e.Parking = plan.Windows[p.Window.ID]
+			if hidden[p.Window.PID] {
+				e.Parking = ParkHide
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func WriteTable(w io.Writer, entries []Entry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DISPLAY\tCOL\tROW\tID\tPID\tAPP\tTITLE\tX\tY\tW\tH\tVISIBLE\tPARKING")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%s\t%s\t%.0f\t%.0f\t%.0f\t%.0f\t%v\t%s\n",
+			e.DisplayID, e.Column, e.Row, e.WindowID, e.PID, e.AppName, e.Title,
+			e.Frame.X, e.Frame.Y, e.Frame.Width, e.Frame.Height, e.Visible, e.Parking)
+	}
+	return tw.Flush()
+}
+
+// WriteFailures lists the windows that keep failing, one per line.
+func WriteFailures(w io.Writer, failures []wm.Failure) {
+	for _, f := range failures {
+		fmt.Fprintf(w, "window %d (pid %d): %s failed %d times, last at %s: %s\n",
+			f.ID, f.PID, f.Op, f.Count, f.Last.Format("15:04:05"), f.Error)
+	}
+}
+
+func WriteJSON(w io.Writer, entries []Entry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
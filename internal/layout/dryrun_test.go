@@ -0,0 +1,50 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/machina/mosaico/internal/strip"
+)
+
+func TestDryRun(t *testing.T) {
+	s := strip.New()
+	s.DisplayID = 1
+	s.SetViewportWidth(1000)
+	s.AddWindow(&strip.Window{ID: 1, PID: 10, BundleID: "com.example.wide", AppName: "Wide", Title: "one"})
+	s.AddWindow(&strip.Window{ID: 2, PID: 20, BundleID: "com.example.app", AppName: "App", Title: "two"})
+	displays := strip.NewDisplays()
+	displays.Strips = append(displays.Strips, s)
+	st := State{
+		Displays: displays,
+		Screens:  []Screen{{DisplayID: 1, Frame: Rect{Width: 2000, Height: 1000}}},
+		Gap:      10,
+	}
+	sizes := NewSizes()
+	sizes.Learn("com.example.wide", Rect{Width: 990}, Rect{Width: 1200})
+
+	entries := DryRun(st, sizes, func(Placement) Parking { return ParkEdge })
+	want := []Rect{
+		{X: 5, Y: 5, Width: 1200, Height: 990},
+		{X: 1215, Y: 5, Width: 780, Height: 990}, // clipped by the screen edge,
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i, e := range entries {
+		if e.Frame != want[i] {
+			t.Errorf("window %d: frame %+v, want %+v", e.WindowID, e.Frame, want[i])
+		}
+	}
+	if s.ViewportWidth != 1000 || s.Columns[0].Fit != 0 {
+		t.Errorf("dry run changed the strip: viewport %v, fit %v", s.ViewportWidth, s.Columns[0].Fit)
+	}
+
+	var table strings.Builder
+	if err := WriteTable(&table, entries); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(table.String(), "Wide") || strings.Contains(table.String(), "com.example.wide") {
+		t.Errorf("APP column should hold the app name:\n%s", table.String())
+	}
+}
@@ -0,0 +1,82 @@
+package layout
+
+import (
+	"github.com/machina/mosaico/internal/strip"
+)
+
+type Rect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Placement is where a window should go. Frame is computed for every
+// window, including those outside the viewport, in global coordinates.
+type Placement struct {
+	Window  *strip.Window
+	Column  int
+	Row     int
+	Frame   Rect
+	Visible bool
+	// Side is -1 or 1 for windows left or right of the viewport, and for
+	// visible windows clipped by its left or right edge.
+	Side int
+}
+
+// Compute lays out a strip on a screen. Columns that intersect the
+// viewport are visible; the rest must be parked. Columns cut off by the
+// edge of the viewport are clipped to it, so they don't reach into a
+// neighbouring display, and parked too if too little of them is left.
+func Compute(s *strip.Strip, screen Rect, gap float64) []Placement {
+	inViewport := make(map[int]bool)
+	for _, i := range s.ColumnsInViewport() {
+		inViewport[i] = true
+	}
+
+	var placements []Placement
+	for i, col := range s.Columns {
+		x := s.ColumnX(i) - s.ViewportOffset + gap/2
+		w := s.ColumnWidth(i) - gap
+
+		visible, side := inViewport[i], 0
+		if visible {
+			left, right := max(x, gap/2), min(x+w, screen.Width-gap/2)
+			if left > x {
+				side = -1
+			}
+			if right < x+w {
+				side = 1
+			}
+			if right-left < gap {
+				visible = false
+			} else {
+				x, w = left, right-left
+			}
+		}
+		if !visible {
+			side = 1
+			if x < 0 {
+				side = -1
+			}
+		}
+
+		// Calculate height per window
+		winCount := len(col.Windows)
+		totalGaps := gap * float64(winCount-1)
+		winHeight := (screen.Height - gap - totalGaps) / float64(winCount)
+
+		for j, win := range col.Windows {
+			winY := gap/2 + float64(j)*(winHeight+gap)
+			placements = append(placements, Placement{
+				Window:  win,
+				Column:  i,
+				Row:     j,
+				Frame:   Rect{X: screen.X + x, Y: screen.Y + winY, Width: w, Height: winHeight},
+				Visible: visible,
+				Side:    side,
+			})
+		}
+	}
+	return placements
+}
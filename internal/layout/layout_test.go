@@ -0,0 +1,94 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/machina/mosaico/internal/strip"
+)
+
+func TestComputeClipsPartlyVisibleColumns(t *testing.T) {
+	screen := Rect{X: 100, Width: 2000, Height: 1000}
+	type column struct {
+		x, width float64
+		visible  bool
+		side     int
+	}
+	tests := []struct {
+		name   string
+		offset float64
+		want   []column
+	}{
+		{
+			name:   "aligned",
+			offset: 0,
+			want: []column{
+				{105, 990, true, 0},
+				{1105, 990, true, 0},
+				{2105, 990, false, 1},
+			},
+		},
+		{
+			name:   "panned half a column",
+			offset: 500,
+			want: []column{
+				{105, 490, true, -1},
+				{605, 990, true, 0},
+				{1605, 490, true, 1},
+			},
+		},
+		{
+			name:   "sliver left",
+			offset: 995,
+			want: []column{
+				{-890, 990, false, -1},
+				{110, 990, true, 0},
+				{1110, 985, true, 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := strip.New()
+			s.SetViewportWidth(screen.Width)
+			for id := range uint32(3) {
+				s.AddWindow(&strip.Window{ID: id + 1})
+			}
+			s.ViewportOffset = tt.offset
+
+			placements := Compute(s, screen, 10)
+			if len(placements) != len(tt.want) {
+				t.Fatalf("got %d placements, want %d", len(placements), len(tt.want))
+			}
+			for i, p := range placements {
+				got := column{p.Frame.X, p.Frame.Width, p.Visible, p.Side}
+				if got != tt.want[i] {
+					t.Errorf("column %d: got %+v, want %+v", i, got, tt.want[i])
+				}
+				if p.Visible && (p.Frame.X < screen.X || p.Frame.X+p.Frame.Width > screen.X+screen.Width) {
+					t.Errorf("column %d: visible frame %+v leaves the screen", i, p.Frame)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileClipped(t *testing.T) {
+	tests := []struct {
+		name string
+		side int
+		want Fix
+	}{
+		{"left edge", -1, Fix{Kind: FixRecenter, X: 105, Y: 5}},
+		{"right edge", 1, Fix{Kind: FixRecenter, X: -95, Y: 5}},
+		{"not clipped", 0, Fix{Kind: FixWiden, ColumnWidth: 710}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Placement{Frame: Rect{X: 105, Y: 5, Width: 500, Height: 990}, Visible: true, Side: tt.side}
+			actual := Rect{X: 50, Y: 5, Width: 700, Height: 990}
+			if got := Reconcile(p, actual, 10); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package layout
+
+import (
+	"fmt"
+)
+
+// Parking is how a window outside the viewport is kept out of sight.
+type Parking string
+
+const (
+	// ParkHide hides the whole app. Only possible when none of the app's
+	// windows should be visible.
+	ParkHide Parking = "hide"
+	// ParkEdge moves the window just past the screen edge.
+	ParkEdge Parking = "edge"
+	// ParkMinimize minimizes the window to the Dock.
+	ParkMinimize Parking = "minimize"
+)
+
+func ParseParking(s string) (Parking, error) {
+	switch p := Parking(s); p {
+	case ParkHide, ParkEdge, ParkMinimize:
+		return p, nil
+	case "":
+		return ParkHide, nil
+	}
+	return "", fmt.Errorf("unknown parking strategy %q (want hide, edge or minimize)", s)
+}
+
+// ParkingPlan says what to do with every app and every parked window.
+type ParkingPlan struct {
+	HideApps   []uint32
+	UnhideApps []uint32
+	// Windows maps parked window IDs to ParkEdge or ParkMinimize. Windows
+	// of hidden apps are not listed.
+	Windows map[uint32]Parking
+}
+
+// ResolveParking decides how to park the invisible placements. An app can
+// only be hidden when all of its windows are out of the viewport; when some
+// are visible, windows that would have used ParkHide fall back to ParkEdge.
+func ResolveParking(placements []Placement, strategyFor func(w Placement) Parking) ParkingPlan {
+	plan := ParkingPlan{Windows: make(map[uint32]Parking)}
+
+	var pids []uint32
+	anyVisible := make(map[uint32]bool)
+	for _, p := range placements {
+		pid := p.Window.PID
+		if _, seen := anyVisible[pid]; !seen {
+			pids = append(pids, pid)
+		}
+		anyVisible[pid] = anyVisible[pid] || p.Visible
+	}
+
+	hide := make(map[uint32]bool)
+	for _, p := range placements {
+		if p.Visible {
+			continue
+		}
+		strategy := strategyFor(p)
+		if strategy == ParkHide && !anyVisible[p.Window.PID] {
+			hide[p.Window.PID] = true
+			continue
+		}
+		if strategy == ParkHide {
+			strategy = ParkEdge
+		}
+		plan.Windows[p.Window.ID] = strategy
+	}
+
+	for _, pid := range pids {
+		if hide[pid] {
+			plan.HideApps = append(plan.HideApps, pid)
+		} else {
+			plan.UnhideApps = append(plan.UnhideApps, pid)
+		}
+	}
+
+	// Windows of hidden apps are covered by hiding the app
+	for _, p := range placements {
+		if hide[p.Window.PID] {
+			delete(plan.Windows, p.Window.ID)
+		}
+	}
+	return plan
+}
+
+// EdgePosition returns where to park a window just past the screen edge on
+// its side of the viewport, leaving one pixel on screen (macOS won't move
+// a window entirely off screen).
+func EdgePosition(p Placement, screen Rect) (x, y float64) {
+	if p.Side < 0 {
+		return screen.X - p.Frame.Width + 1, p.Frame.Y
+	}
+	return screen.X + screen.Width - 1, p.Frame.Y
+}
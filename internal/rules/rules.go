@@ -0,0 +1,172 @@
+// Package rules decides what happens to a window when it appears, from the
+// [[rules]] entries in the config.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/machina/mosaico/internal/config"
+	"github.com/machina/mosaico/internal/wm"
+)
+
+// Builtin rules run after the user's, so a user rule for the same app
+// takes precedence.
+var Builtin = []config.RuleConfig{
+	{BundleID: "com.apple.WindowServer", Ignore: true},
+	{BundleID: "com.apple.dock", Ignore: true},
+	{BundleID: "com.apple.finder", Ignore: true},
+	{BundleID: "com.apple.Spotlight", Ignore: true},
+	{BundleID: "com.apple.notificationcenterui", Ignore: true},
+}
+
+type Rule struct {
+	config.RuleConfig
+	// Index is the rule's position in the config, or -1 for builtin rules.
+	Index int
+	title *regexp.Regexp
+}
+
+func (r *Rule) String() string {
+	var parts []string
+	add := func(k, v string) {
+		if v != "" {
+			parts = append(parts, fmt.Sprintf("%s=%q", k, v))
+		}
+	}
+	add("bundle_id", r.BundleID)
+	add("app", r.App)
+	add("title", r.Title)
+	add("role", r.Role)
+	add("subrole", r.Subrole)
+
+	name := fmt.Sprintf("rule %d", r.Index+1)
+	if r.Index < 0 {
+		name = "builtin rule"
+	}
+	return name + " [" + strings.Join(parts, " ") + "]"
+}
+
+// Actions describes what a rule does, for logs and explanations.
+func (r *Rule) Actions() string {
+	var parts []string
+	if r.Ignore {
+		parts = append(parts, "ignore")
+	}
+	if r.Float {
+		parts = append(parts, "float")
+	}
+	if r.OpenAtColumn > 0 {
+		parts = append(parts, fmt.Sprintf("open-at-column %d", r.OpenAtColumn))
+	}
+	if r.OpenOnDisplay != "" {
+		parts = append(parts, "open-on-display "+r.OpenOnDisplay)
+	}
+	if r.ColumnWidth > 0 {
+		parts = append(parts, fmt.Sprintf("column-width %g", r.ColumnWidth))
+	}
+	if r.StackWithApp {
+		parts = append(parts, "stack-with-app")
+	}
+	if len(parts) == 0 {
+		return "tile"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Tiled reports whether windows matching the rule go into a strip.
+func (r *Rule) Tiled() bool {
+	return r == nil || (!r.Ignore && !r.Float)
+}
+
+// mismatch returns why the window doesn't match, or "" if it does.
+func (r *Rule) mismatch(w wm.WindowInfo) string {
+	switch {
+	case r.BundleID != "" && r.BundleID != w.BundleID:
+		return fmt.Sprintf("bundle ID is %q", w.BundleID)
+	case r.App != "" && r.App != w.OwnerName:
+		return fmt.Sprintf("app is %q", w.OwnerName)
+	case r.title != nil && !r.title.MatchString(w.Title):
+		return fmt.Sprintf("title %q doesn't match", w.Title)
+	case r.Role != "" && r.Role != w.Role:
+		return fmt.Sprintf("role is %q", w.Role)
+	case r.Subrole != "" && r.Subrole != w.Subrole:
+		return fmt.Sprintf("subrole is %q", w.Subrole)
+	case r.MinWidth > 0 && w.Width < r.MinWidth:
+		return fmt.Sprintf("width %.0f < %.0f", w.Width, r.MinWidth)
+	case r.MaxWidth > 0 && w.Width > r.MaxWidth:
+		return fmt.Sprintf("width %.0f > %.0f", w.Width, r.MaxWidth)
+	case r.MinHeight > 0 && w.Height < r.MinHeight:
+		return fmt.Sprintf("height %.0f < %.0f", w.Height, r.MinHeight)
+	case r.MaxHeight > 0 && w.Height > r.MaxHeight:
+		return fmt.Sprintf("height %.0f > %.0f", w.Height, r.MaxHeight)
+	}
+	return ""
+}
+
+type Engine struct {
+	rules []*Rule
+}
+
+// New compiles the user's rules followed by the builtin ones.
+func New(cfg []config.RuleConfig) (*Engine, error) {
+	e := &Engine{}
+	for i, rc := range cfg {
+		r, err := compile(rc, i)
+		if err != nil {
+			return nil, err
+		}
+		e.rules = append(e.rules, r)
+	}
+	for _, rc := range Builtin {
+		r, err := compile(rc, -1)
+		if err != nil {
+			return nil, err
+		}
+		e.rules = append(e.rules, r)
+	}
+	return e, nil
+}
+
+func compile(rc config.RuleConfig, index int) (*Rule, error) {
+	r := &Rule{RuleConfig: rc, Index: index}
+	if rc.Title != "" {
+		re, err := regexp.Compile(rc.Title)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: title: %w", index+1, err)
+		}
+		r.title = re
+	}
+	if rc.Ignore && rc.Float {
+		return nil, fmt.Errorf("rule %d: ignore and float are exclusive", index+1)
+	}
+	if rc.OpenAtColumn < 0 || rc.ColumnWidth < 0 {
+		return nil, fmt.Errorf("rule %d: open_at_column and column_width must be positive", index+1)
+	}
+	return r, nil
+}
+
+// Match returns the first rule matching the window, or nil.
+func (e *Engine) Match(w wm.WindowInfo) *Rule {
+	for _, r := range e.rules {
+		if r.mismatch(w) == "" {
+			return r
+		}
+	}
+	return nil
+}
+
+// Explain describes how every rule was evaluated for the window, up to
+// and including the one that matched.
+func (e *Engine) Explain(w wm.WindowInfo) []string {
+	var lines []string
+	for _, r := range e.rules {
+		if why := r.mismatch(w); why != "" {
+			lines = append(lines, fmt.Sprintf("%s: no match, %s", r, why))
+			continue
+		}
+		return append(lines, fmt.Sprintf("%s: MATCH -> %s", r, r.Actions()))
+	}
+	return append(lines, "no rule matched -> tile")
+}
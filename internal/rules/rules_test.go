@@ -0,0 +1,123 @@
+package rules
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/machina/mosaico/internal/config"
+	"github.com/machina/mosaico/internal/wm"
+)
+
+func TestMatch(t *testing.T) {
+	win := wm.WindowInfo{
+		BundleID:  "com.example.editor",
+		OwnerName: "Editor",
+		Title:     "notes.txt — Editor",
+		Role:      "AXWindow",
+		Subrole:   "AXStandardWindow",
+		Width:     800,
+		Height:    600,
+	}
+	tests := []struct {
+		name  string
+		rules []config.RuleConfig
+		win   func(w *wm.WindowInfo)
+		want  int // index of the matching rule, -1 for none
+	}{
+		{"no rules", nil, nil, -1},
+		{"bundle ID", []config.RuleConfig{{BundleID: "com.example.editor"}}, nil, 0},
+		{"other bundle ID", []config.RuleConfig{{BundleID: "com.example.other"}}, nil, -1},
+		{"app name", []config.RuleConfig{{App: "Editor"}}, nil, 0},
+		{"app name is exact", []config.RuleConfig{{App: "Edit"}}, nil, -1},
+		{"title regex", []config.RuleConfig{{Title: `\.txt\b`}}, nil, 0},
+		{"title regex anchored", []config.RuleConfig{{Title: "^Preferences$"}}, nil, -1},
+		{"role and subrole", []config.RuleConfig{{Role: "AXWindow", Subrole: "AXDialog"}}, nil, -1},
+		{"every field must match", []config.RuleConfig{{BundleID: "com.example.editor", Title: "^Preferences"}}, nil, -1},
+		{"within size bounds", []config.RuleConfig{{MinWidth: 800, MaxWidth: 800, MinHeight: 600, MaxHeight: 600}}, nil, 0},
+		{"narrower than min_width", []config.RuleConfig{{MinWidth: 801}}, nil, -1},
+		{"wider than max_width", []config.RuleConfig{{MaxWidth: 799}}, nil, -1},
+		{"shorter than min_height", []config.RuleConfig{{MinHeight: 601}}, nil, -1},
+		{"taller than max_height", []config.RuleConfig{{MaxHeight: 599}}, nil, -1},
+		{"small dialog", []config.RuleConfig{{MaxWidth: 500, MaxHeight: 400, Float: true}},
+			func(w *wm.WindowInfo) { w.Width, w.Height = 400, 300 }, 0},
+		{"first match wins", []config.RuleConfig{
+			{BundleID: "com.example.other", Ignore: true},
+			{BundleID: "com.example.editor", Float: true},
+			{App: "Editor", Ignore: true},
+		}, nil, 1},
+		{"user rule before builtin", []config.RuleConfig{{BundleID: "com.apple.finder"}},
+			func(w *wm.WindowInfo) { w.BundleID = "com.apple.finder" }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := win
+			if tt.win != nil {
+				tt.win(&w)
+			}
+			r := e.Match(w)
+			got := -1
+			if r != nil {
+				got = r.Index
+			}
+			if got != tt.want {
+				t.Errorf("Match() = rule %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchBuiltin(t *testing.T) {
+	e, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := e.Match(wm.WindowInfo{BundleID: "com.apple.finder"})
+	if r == nil || r.Index != -1 || r.Tiled() {
+		t.Errorf("Match(Finder) = %v, want the builtin ignore rule", r)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.RuleConfig
+		want string
+	}{
+		{"invalid title regex", config.RuleConfig{Title: "(unclosed"}, "rule 2: title"},
+		{"ignore and float", config.RuleConfig{Ignore: true, Float: true}, "rule 2: ignore and float are exclusive"},
+		{"negative column", config.RuleConfig{OpenAtColumn: -1}, "rule 2: open_at_column and column_width must be positive"},
+		{"negative width", config.RuleConfig{ColumnWidth: -0.5}, "rule 2: open_at_column and column_width must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New([]config.RuleConfig{{App: "Editor"}, tt.rule})
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("New() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	e, err := New([]config.RuleConfig{
+		{BundleID: "com.example.other"},
+		{Title: "^Prefs", Float: true},
+		{App: "Editor", Ignore: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := e.Explain(wm.WindowInfo{BundleID: "com.example.editor", OwnerName: "Editor", Title: "Prefs"})
+	want := []string{
+		`rule 1 [bundle_id="com.example.other"]: no match, bundle ID is "com.example.editor"`,
+		`rule 2 [title="^Prefs"]: MATCH -> float`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Explain() =\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
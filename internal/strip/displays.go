@@ -0,0 +1,254 @@
+package strip
+
+import (
+	"sync"
+)
+
+// Displays holds one strip per display, ordered left to right.
+type Displays struct {
+	Strips  []*Strip   `json:"strips"`
+	Focused int        `json:"focused"`
+	Mutex   sync.Mutex `json:"-"`
+}
+
+func NewDisplays() *Displays {
+	return &Displays{Strips: make([]*Strip, 0)}
+}
+
+// Sync makes the strips match the given display IDs (left to right). Strips
+// for displays that no longer exist are dropped and their columns moved to
+// the first remaining display, remembering where they came from so they go
+// back when their display returns. newStrip is called for displays without
+// one.
+func (d *Displays) Sync(ids []uint32, newStrip func(id uint32) *Strip) {
+	var focusedID uint32
+	if fs := d.FocusedStrip(); fs != nil {
+		focusedID = fs.DisplayID
+	}
+
+	existing := make(map[uint32]*Strip)
+	for _, s := range d.Strips {
+		existing[s.DisplayID] = s
+	}
+
+	strips := make([]*Strip, 0, len(ids))
+	for _, id := range ids {
+		s, ok := existing[id]
+		if !ok {
+			s = newStrip(id)
+			s.DisplayID = id
+		}
+		delete(existing, id)
+		strips = append(strips, s)
+	}
+	old := d.Strips
+	d.Strips = strips
+
+	d.returnHome()
+	if len(d.Strips) > 0 {
+		target := d.Strips[0]
+		for _, orphan := range old {
+			if existing[orphan.DisplayID] == nil {
+				continue
+			}
+			for _, col := range orphan.Columns {
+				if col.Home == 0 {
+					col.Home = orphan.DisplayID
+				}
+			}
+			target.Columns = append(target.Columns, orphan.Columns...)
+			target.clampFocus()
+		}
+	}
+
+	d.Focused = 0
+	for i, s := range d.Strips {
+		if s.DisplayID == focusedID {
+			d.Focused = i
+		}
+	}
+}
+
+// returnHome moves columns that were evacuated from a display back to it,
+// if it is present again.
+func (d *Displays) returnHome() {
+	for _, home := range d.Strips {
+		for _, s := range d.Strips {
+			if s == home {
+				continue
+			}
+			kept := s.Columns[:0]
+			moved := false
+			for _, col := range s.Columns {
+				if col.Home == home.DisplayID {
+					col.Home = 0
+					home.Columns = append(home.Columns, col)
+					moved = true
+					continue
+				}
+				kept = append(kept, col)
+			}
+			s.Columns = kept
+			if moved {
+				s.clampFocus()
+				home.clampFocus()
+			}
+		}
+	}
+}
+
+func (d *Displays) FocusedStrip() *Strip {
+	if len(d.Strips) == 0 {
+		return nil
+	}
+	if d.Focused >= len(d.Strips) {
+		d.Focused = len(d.Strips) - 1
+	}
+	return d.Strips[d.Focused]
+}
+
+func (d *Displays) ForDisplay(id uint32) *Strip {
+	for _, s := range d.Strips {
+		if s.DisplayID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// FindWindow returns the strip holding the window with the given ID.
+func (d *Displays) FindWindow(id uint32) *Strip {
+	for _, s := range d.Strips {
+		if s.GetAllWindowIDs()[id] {
+			return s
+		}
+	}
+	return nil
+}
+
+// Window returns the window with the given ID, or nil.
+func (d *Displays) Window(id uint32) *Window {
+	for _, s := range d.Strips {
+		for _, col := range s.Columns {
+			for _, win := range col.Windows {
+				if win.ID == id {
+					return win
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// FocusWindow focuses the window with the given ID and the display it is
+// on. It reports whether the window was found.
+func (d *Displays) FocusWindow(id uint32) bool {
+	for i, s := range d.Strips {
+		if s.FocusWindow(id) {
+			d.Focused = i
+			return true
+		}
+	}
+	return false
+}
+
+// FocusedWindow returns the focused window of the focused display, or nil.
+func (d *Displays) FocusedWindow() *Window {
+	s := d.FocusedStrip()
+	if s == nil || len(s.Columns) == 0 {
+		return nil
+	}
+	col := s.Columns[s.FocusedCol]
+	if len(col.Windows) == 0 {
+		return nil
+	}
+	return col.Windows[col.Focused]
+}
+
+func (d *Displays) GetAllWindowIDs() map[uint32]bool {
+	ids := make(map[uint32]bool)
+	for _, s := range d.Strips {
+		for id := range s.GetAllWindowIDs() {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+func (d *Displays) GetAllWindowPIDs() map[uint32]bool {
+	pids := make(map[uint32]bool)
+	for _, s := range d.Strips {
+		for pid := range s.GetAllWindowPIDs() {
+			pids[pid] = true
+		}
+	}
+	return pids
+}
+
+func (d *Displays) RemoveWindowByPID(pid uint32) {
+	for _, s := range d.Strips {
+		s.RemoveWindowByPID(pid)
+	}
+}
+
+func (d *Displays) RemoveWindowByID(id uint32) {
+	for _, s := range d.Strips {
+		s.RemoveWindowByID(id)
+	}
+}
+
+// FocusNext moves focus to the display on the right, wrapping around.
+func (d *Displays) FocusNext() {
+	d.focusDelta(1)
+}
+
+// FocusPrev moves focus to the display on the left, wrapping around.
+func (d *Displays) FocusPrev() {
+	d.focusDelta(-1)
+}
+
+func (d *Displays) focusDelta(delta int) {
+	if len(d.Strips) == 0 {
+		return
+	}
+	d.Focused = d.neighbour(delta)
+}
+
+func (d *Displays) neighbour(delta int) int {
+	n := len(d.Strips)
+	return ((d.Focused+delta)%n + n) % n
+}
+
+// MoveWindowToDisplay moves the focused window to a new column on the
+// neighbouring display, next to that display's focused column. Focus
+// follows the window.
+func (d *Displays) MoveWindowToDisplay(delta int) {
+	if len(d.Strips) < 2 {
+		return
+	}
+	src := d.FocusedStrip()
+	win := src.TakeFocusedWindow()
+	if win == nil {
+		return
+	}
+
+	d.Focused = d.neighbour(delta)
+	d.FocusedStrip().InsertColumn(&Column{Windows: []*Window{win}})
+}
+
+// MoveColumnToDisplay moves the focused column to the neighbouring display.
+func (d *Displays) MoveColumnToDisplay(delta int) {
+	if len(d.Strips) < 2 {
+		return
+	}
+	src := d.FocusedStrip()
+	col := src.TakeFocusedColumn()
+	if col == nil {
+		return
+	}
+
+	// Moved on purpose, so it stays where it was put
+	col.Home = 0
+	d.Focused = d.neighbour(delta)
+	d.FocusedStrip().InsertColumn(col)
+}
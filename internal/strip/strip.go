@@ -2,65 +2,98 @@ package strip
 
 import (
 	"fmt"
-	"os"
+	"slices"
 	"sync"
 )
 
 type Strip struct {
-	Columns       []*Column
-	FocusedCol    int
-	ViewportStart int
-	VisibleCount  int
-	Mutex         sync.Mutex
+	DisplayID  uint32    `json:"display_id"`
+	Columns    []*Column `json:"columns"`
+	FocusedCol int       `json:"focused_col"`
+	// ViewportOffset is the x position, in pixels, of the left edge of the
+	// viewport within the strip.
+	ViewportOffset float64    `json:"viewport_offset"`
+	ViewportWidth  float64    `json:"viewport_width"`
+	VisibleCount   int        `json:"visible_count"`
+	Mutex          sync.Mutex `json:"-"`
 }
 
 type Column struct {
-	Windows []*Window // Should we have more than one window per column?
-	Focused int
+	Windows []*Window `json:"windows"` // Should we have more than one window per column?
+	Focused int       `json:"focused"`
+	Width   float64   `json:"width,omitempty"` // 0 uses the strip's default column width
+	// Fit is the width the column grew to for a window that wouldn't
+	// shrink to it. It holds only until a window leaves the column or the
+	// screen width changes; the layout fits it again if still needed.
+	Fit float64 `json:"fit,omitempty"`
+	// Home is the display the column was evacuated from when it was
+	// unplugged, 0 otherwise.
+	Home uint32 `json:"home,omitempty"`
 }
 
 type Window struct {
-	ID    uint32
-	PID   uint32
-	Title string
+	ID       uint32 `json:"id"`
+	PID      uint32 `json:"pid"`
+	BundleID string `json:"bundle_id,omitempty"`
+	AppName  string `json:"app_name,omitempty"`
+	Title    string `json:"title"`
+}
+
+// Label is the title, or the app name for untitled windows.
+func (w *Window) Label() string {
+	if w.Title != "" {
+		return w.Title
+	}
+	return w.AppName
 }
 
 func New() *Strip {
 	s := &Strip{
-		Columns:       make([]*Column, 0),
-		FocusedCol:    0,
-		VisibleCount:  2,
-		ViewportStart: 0,
+		Columns:        make([]*Column, 0),
+		FocusedCol:     0,
+		VisibleCount:   2,
+		ViewportOffset: 0,
 	}
 	return s
 }
 
+// Clone returns a copy of the strip that can be laid out without touching
+// the original.
+func (s *Strip) Clone() *Strip {
+	c := &Strip{
+		DisplayID:      s.DisplayID,
+		Columns:        make([]*Column, len(s.Columns)),
+		FocusedCol:     s.FocusedCol,
+		ViewportOffset: s.ViewportOffset,
+		ViewportWidth:  s.ViewportWidth,
+		VisibleCount:   s.VisibleCount,
+	}
+	for i, col := range s.Columns {
+		cc := *col
+		cc.Windows = make([]*Window, len(col.Windows))
+		for j, win := range col.Windows {
+			w := *win
+			cc.Windows[j] = &w
+		}
+		c.Columns[i] = &cc
+	}
+	return c
+}
+
 func (s *Strip) clampFocus() {
 	if len(s.Columns) == 0 {
 		s.FocusedCol = 0
-		s.ViewportStart = 0
+		s.ViewportOffset = 0
 		return
 	}
 	if s.FocusedCol >= len(s.Columns) {
 		s.FocusedCol = len(s.Columns) - 1
 	}
-	if s.ViewportStart >= len(s.Columns) {
-		s.ViewportStart = len(s.Columns) - 1
-	}
-
-	if s.FocusedCol < s.ViewportStart {
-		s.ViewportStart = s.FocusedCol
-	}
-	if s.FocusedCol >=
-		s.ViewportStart+s.VisibleCount {
-		s.ViewportStart = s.FocusedCol -
-			s.VisibleCount + 1
+	if s.FocusedCol < 0 {
+		s.FocusedCol = 0
 	}
 
-	// Clamp ViewportStart
-	if s.ViewportStart < 0 {
-		s.ViewportStart = 0
-	}
+	s.ScrollToColumn(s.FocusedCol)
 }
 
 func (s *Strip) AddWindow(w *Window) {
@@ -70,7 +103,93 @@ func (s *Strip) AddWindow(w *Window) {
 	}
 	nc := &Column{Windows: []*Window{w}}
 	s.Columns = append(s.Columns, nc)
-	fmt.Printf("AddWindow: now %d columns\n", len(s.Columns))
+}
+
+// InsertColumn inserts a column to the right of the focused one and
+// focuses it.
+func (s *Strip) InsertColumn(c *Column) {
+	idx := 0
+	if len(s.Columns) > 0 {
+		idx = s.FocusedCol + 1
+	}
+	s.Columns = slices.Insert(s.Columns, idx, c)
+	s.FocusedCol = idx
+	s.clampFocus()
+}
+
+// PlaceColumn inserts a column at position n (1-indexed), or at the end
+// when n is 0 or past the last column. Focus stays on the same column.
+func (s *Strip) PlaceColumn(c *Column, n int) {
+	idx := n - 1
+	if idx < 0 || idx > len(s.Columns) {
+		idx = len(s.Columns)
+	}
+	s.Columns = slices.Insert(s.Columns, idx, c)
+	if len(s.Columns) > 1 && idx <= s.FocusedCol {
+		s.FocusedCol++
+	}
+	s.clampFocus()
+}
+
+// InsertWindow puts a window into column stack at row, or, when stack is
+// nil, into a new column in front of before (at the end when before is nil
+// too). Focus follows the window. It returns the window's column.
+func (s *Strip) InsertWindow(win *Window, before, stack *Column, row int) *Column {
+	if i := slices.Index(s.Columns, stack); stack != nil && i >= 0 {
+		row = max(0, min(row, len(stack.Windows)))
+		stack.Windows = slices.Insert(stack.Windows, row, win)
+		stack.Focused = row
+		s.FocusedCol = i
+		s.clampFocus()
+		return stack
+	}
+
+	idx := slices.Index(s.Columns, before)
+	if before == nil || idx < 0 {
+		idx = len(s.Columns)
+	}
+	col := &Column{Windows: []*Window{win}}
+	s.Columns = slices.Insert(s.Columns, idx, col)
+	s.FocusedCol = idx
+	s.clampFocus()
+	return col
+}
+
+// AppColumn returns the first column holding a window of the app, or nil.
+func (s *Strip) AppColumn(bundleID string) *Column {
+	for _, col := range s.Columns {
+		for _, win := range col.Windows {
+			if win.BundleID == bundleID {
+				return col
+			}
+		}
+	}
+	return nil
+}
+
+// TakeFocusedWindow removes the focused window from the strip and returns it.
+func (s *Strip) TakeFocusedWindow() *Window {
+	if len(s.Columns) == 0 {
+		return nil
+	}
+	col := s.Columns[s.FocusedCol]
+	if len(col.Windows) == 0 {
+		return nil
+	}
+	win := col.Windows[col.Focused]
+	s.RemoveWindow()
+	return win
+}
+
+// TakeFocusedColumn removes the focused column from the strip and returns it.
+func (s *Strip) TakeFocusedColumn() *Column {
+	if len(s.Columns) == 0 {
+		return nil
+	}
+	col := s.Columns[s.FocusedCol]
+	s.Columns = append(s.Columns[:s.FocusedCol], s.Columns[s.FocusedCol+1:]...)
+	s.clampFocus()
+	return col
 }
 
 func (s *Strip) RemoveWindow() {
@@ -85,21 +204,18 @@ func (s *Strip) RemoveWindow() {
 		return
 	}
 
-	col.Windows = append(col.Windows[:col.Focused], col.Windows[col.Focused+1:]...)
+	col.removeWindow(col.Focused)
 }
 
 func (s *Strip) RemoveWindowByID(id uint32) {
 	for colIdx, col := range s.Columns {
 		for winIdx, win := range col.Windows {
 			if win.ID == id {
-				// Remove window from column
-				col.Windows = append(col.Windows[:winIdx], col.Windows[winIdx+1:]...)
+				col.removeWindow(winIdx)
 
 				// If column is empty, remove it
 				if len(col.Windows) == 0 {
 					s.Columns = append(s.Columns[:colIdx], s.Columns[colIdx+1:]...)
-				} else {
-					col.clampFocus()
 				}
 
 				s.clampFocus()
@@ -113,14 +229,11 @@ func (s *Strip) RemoveWindowByPID(pid uint32) {
 	for colIdx, col := range s.Columns {
 		for winIdx, win := range col.Windows {
 			if win.PID == pid {
-				// Remove window from column
-				col.Windows = append(col.Windows[:winIdx], col.Windows[winIdx+1:]...)
+				col.removeWindow(winIdx)
 
 				// If column is empty, remove it
 				if len(col.Windows) == 0 {
 					s.Columns = append(s.Columns[:colIdx], s.Columns[colIdx+1:]...)
-				} else {
-					col.clampFocus()
 				}
 
 				s.clampFocus()
@@ -129,20 +242,13 @@ func (s *Strip) RemoveWindowByPID(pid uint32) {
 		}
 	}
 }
-func (s *Strip) ScrollRight() {
-	fmt.Fprintf(os.Stderr, "ScrollRight: FocusedCol=%d, ViewportStart=%d, Columns=%d\n",
-		s.FocusedCol, s.ViewportStart, len(s.Columns))
 
+func (s *Strip) ScrollRight() {
 	if s.FocusedCol >= len(s.Columns)-1 {
 		return
 	}
 	s.FocusedCol++
-
-	if s.FocusedCol >= s.ViewportStart+s.VisibleCount {
-		s.ViewportStart++
-	}
-
-	fmt.Fprintf(os.Stderr, "After: FocusedCol=%d, ViewportStart=%d\n", s.FocusedCol, s.ViewportStart)
+	s.ScrollToColumn(s.FocusedCol)
 }
 
 func (s *Strip) ScrollLeft() {
@@ -150,10 +256,7 @@ func (s *Strip) ScrollLeft() {
 		return
 	}
 	s.FocusedCol--
-
-	if s.FocusedCol < s.ViewportStart {
-		s.ViewportStart--
-	}
+	s.ScrollToColumn(s.FocusedCol)
 }
 
 func (s *Strip) ScrollUp() {
@@ -189,14 +292,13 @@ func (s *Strip) ScrollDown() {
 	col.Focused += 1
 }
 
+// GetVisibleColumns returns the columns that intersect the viewport.
 func (s *Strip) GetVisibleColumns() []*Column {
-	if len(s.Columns) == 0 {
-		return []*Column{}
+	cols := []*Column{}
+	for _, i := range s.ColumnsInViewport() {
+		cols = append(cols, s.Columns[i])
 	}
-
-	end := min(s.ViewportStart+s.VisibleCount, len(s.Columns))
-
-	return s.Columns[s.ViewportStart:end]
+	return cols
 }
 
 func (s *Strip) MoveWindowLeft() {
@@ -209,8 +311,7 @@ func (s *Strip) MoveWindowLeft() {
 
 	leftColumn := s.Columns[s.FocusedCol-1]
 
-	col.Windows = append(col.Windows[:col.Focused], col.Windows[col.Focused+1:]...)
-	col.clampFocus()
+	col.removeWindow(col.Focused)
 
 	if len(col.Windows) == 0 {
 		s.Columns = append(s.Columns[:s.FocusedCol], s.Columns[s.FocusedCol+1:]...)
@@ -224,11 +325,13 @@ func (s *Strip) MoveWindowLeft() {
 }
 
 func (s *Strip) MoveWindowRight() {
+	if len(s.Columns) == 0 {
+		return
+	}
 	col := s.Columns[s.FocusedCol]
 	win := col.Windows[col.Focused]
 
-	col.Windows = append(col.Windows[:col.Focused], col.Windows[col.Focused+1:]...)
-	col.clampFocus()
+	col.removeWindow(col.Focused)
 
 	if s.FocusedCol >= len(s.Columns)-1 {
 		newCol := &Column{Windows: []*Window{win}}
@@ -274,6 +377,14 @@ func (c *Column) MoveWindowDown() {
 	}
 }
 
+// removeWindow takes window i out of the column. Its fitted width may have
+// been for that window, so it is dropped too.
+func (c *Column) removeWindow(i int) {
+	c.Windows = append(c.Windows[:i], c.Windows[i+1:]...)
+	c.Fit = 0
+	c.clampFocus()
+}
+
 func (c *Column) clampFocus() {
 	if len(c.Windows) == 0 {
 		c.Focused = 0
@@ -319,6 +430,22 @@ func (s *Strip) GetAllWindowPIDs() map[uint32]bool {
 	return pids
 }
 
+// FocusWindow focuses the window with the given ID and scrolls its column
+// into view. It reports whether the window is in the strip.
+func (s *Strip) FocusWindow(id uint32) bool {
+	for i, col := range s.Columns {
+		for j, win := range col.Windows {
+			if win.ID == id {
+				s.FocusedCol = i
+				col.Focused = j
+				s.ScrollToColumn(i)
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // JumpToColumn moves focus to column n (1-indexed)
 func (s *Strip) JumpToColumn(n int) {
 	target := n - 1
@@ -329,6 +456,16 @@ func (s *Strip) JumpToColumn(n int) {
 	s.clampFocus()
 }
 
+// PageBy moves focus by pages of VisibleCount columns, negative to the
+// left, stopping at either end.
+func (s *Strip) PageBy(pages int) {
+	if len(s.Columns) == 0 {
+		return
+	}
+	s.FocusedCol = min(max(s.FocusedCol+pages*max(s.VisibleCount, 1), 0), len(s.Columns)-1)
+	s.clampFocus()
+}
+
 // MoveToColumn moves current window to column n (1-indexed)
 func (s *Strip) MoveToColumn(n int) {
 	target := n - 1
@@ -344,17 +481,16 @@ func (s *Strip) MoveToColumn(n int) {
 	win := col.Windows[col.Focused]
 
 	// Remove from current column
-	col.Windows = append(col.Windows[:col.Focused], col.Windows[col.Focused+1:]...)
+	col.removeWindow(col.Focused)
 
 	// If current column is now empty, remove it
 	if len(col.Windows) == 0 {
 		s.Columns = append(s.Columns[:s.FocusedCol], s.Columns[s.FocusedCol+1:]...)
-	} else {
-		col.clampFocus()
 	}
 
-	// Create columns up to target if needed
-	for len(s.Columns) <= target {
+	// Past the end, open a new last column
+	target = min(target, len(s.Columns))
+	if target == len(s.Columns) {
 		s.Columns = append(s.Columns, &Column{Windows: []*Window{}})
 	}
 
@@ -0,0 +1,47 @@
+package strip
+
+import "testing"
+
+// fitted returns a strip with two 500-wide columns on a 1000-wide
+// screen, the first holding two windows and fitted to 800.
+func fitted() *Strip {
+	s := New()
+	s.SetViewportWidth(1000)
+	s.Columns = []*Column{
+		{Windows: []*Window{{ID: 1}, {ID: 2}}, Fit: 800},
+		{Windows: []*Window{{ID: 3}}},
+	}
+	return s
+}
+
+func TestColumnFit(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(s *Strip)
+		want float64 // width of the first column
+	}{
+		{"fitted", func(s *Strip) {}, 800},
+		{"explicit width below the fit", func(s *Strip) { s.Columns[0].Width = 600 }, 800},
+		{"explicit width above the fit", func(s *Strip) { s.Columns[0].Width = 900 }, 900},
+		{"window closes", func(s *Strip) { s.RemoveWindowByID(2) }, 500},
+		{"app quits", func(s *Strip) { s.RemoveWindowByPID(0) }, 500},
+		{"window moves out", func(s *Strip) { s.MoveWindowRight() }, 500},
+		{"window moves to another column", func(s *Strip) { s.MoveToColumn(2) }, 500},
+		{"window moves in", func(s *Strip) {
+			s.FocusedCol = 1
+			s.MoveWindowLeft()
+		}, 800},
+		{"window taken", func(s *Strip) { s.TakeFocusedWindow() }, 500},
+		{"screen resized", func(s *Strip) { s.SetViewportWidth(1200) }, 600},
+		{"same screen width", func(s *Strip) { s.SetViewportWidth(1000) }, 800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fitted()
+			tt.do(s)
+			if got := s.ColumnWidth(0); got != tt.want {
+				t.Errorf("ColumnWidth(0) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
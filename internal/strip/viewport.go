@@ -0,0 +1,139 @@
+package strip
+
+import "slices"
+
+// epsilon absorbs rounding when column widths don't divide the screen evenly.
+const epsilon = 0.5
+
+// nominalColumnWidth sizes columns when the strip has not been given a
+// screen width (e.g. in the TUI without a display).
+const nominalColumnWidth = 1000
+
+func (s *Strip) viewportWidth() float64 {
+	if s.ViewportWidth > 0 {
+		return s.ViewportWidth
+	}
+	return float64(max(s.VisibleCount, 1)) * nominalColumnWidth
+}
+
+// SetViewportWidth resizes the viewport, keeping the focused column visible
+// when the width changes. Columns are fitted to their windows again at the
+// new width.
+func (s *Strip) SetViewportWidth(w float64) {
+	if w == s.ViewportWidth {
+		return
+	}
+	s.ViewportWidth = w
+	for _, col := range s.Columns {
+		col.Fit = 0
+	}
+	s.ScrollToColumn(s.FocusedCol)
+}
+
+// ColumnWidth returns the width of column i, gaps included.
+func (s *Strip) ColumnWidth(i int) float64 {
+	w := s.viewportWidth() / float64(max(s.VisibleCount, 1))
+	if i < 0 || i >= len(s.Columns) {
+		return w
+	}
+	if s.Columns[i].Width > 0 {
+		w = s.Columns[i].Width
+	}
+	return max(w, s.Columns[i].Fit)
+}
+
+// ColumnX returns the x position of column i within the strip.
+func (s *Strip) ColumnX(i int) float64 {
+	x := float64(0)
+	for j := 0; j < i && j < len(s.Columns); j++ {
+		x += s.ColumnWidth(j)
+	}
+	return x
+}
+
+// TotalWidth returns the width of the whole strip.
+func (s *Strip) TotalWidth() float64 {
+	return s.ColumnX(len(s.Columns))
+}
+
+// ScrollToColumn scrolls the viewport by the smallest amount that makes
+// column i fully visible. Columns wider than the viewport are aligned to
+// its left edge.
+func (s *Strip) ScrollToColumn(i int) {
+	if i < 0 || i >= len(s.Columns) {
+		return
+	}
+	x := s.ColumnX(i)
+	w := s.ColumnWidth(i)
+	vw := s.viewportWidth()
+
+	if x+w > s.ViewportOffset+vw {
+		s.ViewportOffset = x + w - vw
+	}
+	if x < s.ViewportOffset {
+		s.ViewportOffset = x
+	}
+	s.clampOffset()
+}
+
+// CenterColumn scrolls the viewport so column i sits in its middle.
+func (s *Strip) CenterColumn(i int) {
+	if i < 0 || i >= len(s.Columns) {
+		return
+	}
+	s.ViewportOffset = s.ColumnX(i) + s.ColumnWidth(i)/2 - s.viewportWidth()/2
+	s.clampOffset()
+}
+
+// ScrollBy moves the viewport by dx pixels without changing focus.
+func (s *Strip) ScrollBy(dx float64) {
+	s.ViewportOffset += dx
+	s.clampOffset()
+}
+
+// Pan scrolls the viewport by dx pixels, moving focus to the nearest column
+// still in view when the focused one leaves it.
+func (s *Strip) Pan(dx float64) {
+	s.ScrollBy(dx)
+	in := s.ColumnsInViewport()
+	if len(in) == 0 || slices.Contains(in, s.FocusedCol) {
+		return
+	}
+	if s.FocusedCol < in[0] {
+		s.FocusedCol = in[0]
+	} else {
+		s.FocusedCol = in[len(in)-1]
+	}
+}
+
+// clampOffset keeps the viewport within the strip.
+func (s *Strip) clampOffset() {
+	maxOffset := max(s.TotalWidth()-s.viewportWidth(), 0)
+	s.ViewportOffset = min(max(s.ViewportOffset, 0), maxOffset)
+}
+
+// ColumnsInViewport returns the indices of the columns that intersect the
+// viewport, left to right.
+func (s *Strip) ColumnsInViewport() []int {
+	var idx []int
+	start, end := s.ViewportOffset, s.ViewportOffset+s.viewportWidth()
+	x := float64(0)
+	for i := range s.Columns {
+		w := s.ColumnWidth(i)
+		if x < end-epsilon && x+w > start+epsilon {
+			idx = append(idx, i)
+		}
+		x += w
+	}
+	return idx
+}
+
+// ColumnFullyVisible reports whether column i lies entirely inside the
+// viewport.
+func (s *Strip) ColumnFullyVisible(i int) bool {
+	if i < 0 || i >= len(s.Columns) {
+		return false
+	}
+	x := s.ColumnX(i)
+	return x >= s.ViewportOffset-epsilon && x+s.ColumnWidth(i) <= s.ViewportOffset+s.viewportWidth()+epsilon
+}
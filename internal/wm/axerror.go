@@ -0,0 +1,168 @@
+package wm
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AXError is an Accessibility API error code.
+type AXError int32
+
+const (
+	AXErrFailure                  AXError = -25200
+	AXErrIllegalArgument          AXError = -25201
+	AXErrInvalidUIElement         AXError = -25202
+	AXErrInvalidUIElementObserver AXError = -25203
+	AXErrCannotComplete           AXError = -25204
+	AXErrAttributeUnsupported     AXError = -25205
+	AXErrActionUnsupported        AXError = -25206
+	AXErrNotImplemented           AXError = -25208
+	AXErrAPIDisabled              AXError = -25211
+	AXErrNoValue                  AXError = -25212
+)
+
+// Errors an AXError matches with errors.Is.
+var (
+	ErrInvalidElement = errors.New("window element is no longer valid")
+	ErrCannotComplete = errors.New("app did not respond")
+	ErrAPIDisabled    = errors.New("accessibility access is disabled")
+	ErrNotImplemented = errors.New("app does not support accessibility")
+	// ErrBackingOff is returned without asking the app, while a window is
+	// skipped after its app did not respond.
+	ErrBackingOff = errors.New("app did not respond lately, not asking again yet")
+)
+
+func (e AXError) Error() string {
+	switch e {
+	case AXErrInvalidUIElement:
+		return ErrInvalidElement.Error()
+	case AXErrCannotComplete:
+		return ErrCannotComplete.Error()
+	case AXErrAPIDisabled:
+		return ErrAPIDisabled.Error()
+	case AXErrNotImplemented:
+		return ErrNotImplemented.Error()
+	case AXErrAttributeUnsupported:
+		return "attribute not supported"
+	case AXErrActionUnsupported:
+		return "action not supported"
+	case AXErrNoValue:
+		return "no value"
+	}
+	return fmt.Sprintf("AXError %d", int32(e))
+}
+
+func (e AXError) Is(target error) bool {
+	switch target {
+	case ErrInvalidElement:
+		return e == AXErrInvalidUIElement
+	case ErrCannotComplete:
+		return e == AXErrCannotComplete
+	case ErrAPIDisabled:
+		return e == AXErrAPIDisabled
+	case ErrNotImplemented:
+		return e == AXErrNotImplemented
+	}
+	return false
+}
+
+// Transient reports whether an operation that failed with err may succeed
+// if retried right away: the cached element went stale and can be looked
+// up again. An app that did not respond is not retried right away; it is
+// likely hung and would stall every other window in the meantime, so
+// Failures backs off instead.
+func Transient(err error) bool {
+	return errors.Is(err, ErrInvalidElement)
+}
+
+// failureReport is how many consecutive failures of a window it takes to
+// log them.
+const failureReport = 3
+
+// A window whose app did not respond is skipped for backoffMin, doubling
+// with every further failure up to backoffMax.
+const (
+	backoffMin = 500 * time.Millisecond
+	backoffMax = 30 * time.Second
+)
+
+// Failure is a run of consecutive failed operations on one window.
+type Failure struct {
+	PID   uint32    `json:"pid"`
+	ID    uint32    `json:"id"`
+	Op    string    `json:"op"`
+	Error string    `json:"error"`
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"`
+
+	backoff time.Duration
+	next    time.Time // when the window may be tried again
+}
+
+// Failures tracks consecutive failures per window, logging them to log
+// once they repeat, and backs off from windows whose app did not respond.
+// Any success clears a window's record.
+type Failures struct {
+	mu   sync.Mutex
+	byID map[uint32]*Failure
+	log  io.Writer
+	now  func() time.Time
+}
+
+func NewFailures(log io.Writer) *Failures {
+	return &Failures{byID: make(map[uint32]*Failure), log: log, now: time.Now}
+}
+
+func (f *Failures) Fail(pid, id uint32, op string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rec, ok := f.byID[id]
+	if !ok {
+		rec = &Failure{PID: pid, ID: id}
+		f.byID[id] = rec
+	}
+	rec.Op, rec.Error, rec.Last = op, err.Error(), f.now()
+	rec.Count++
+	if errors.Is(err, ErrCannotComplete) {
+		rec.backoff = min(max(2*rec.backoff, backoffMin), backoffMax)
+		rec.next = rec.Last.Add(rec.backoff)
+	}
+	if rec.Count == failureReport || rec.Count%(failureReport*10) == 0 {
+		fmt.Fprintf(f.log, "WARNING: window %d (pid %d): %s failed %d times in a row: %v\n", id, pid, op, rec.Count, err)
+	}
+}
+
+// Skip reports whether to leave the window alone for now, because its app
+// did not respond lately.
+func (f *Failures) Skip(id uint32) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rec, ok := f.byID[id]
+	return ok && f.now().Before(rec.next)
+}
+
+func (f *Failures) OK(id uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.byID, id)
+}
+
+// List returns the windows that failed at least failureReport times in a
+// row, by window ID.
+func (f *Failures) List() []Failure {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []Failure
+	for _, rec := range f.byID {
+		if rec.Count >= failureReport {
+			list = append(list, *rec)
+		}
+	}
+	slices.SortFunc(list, func(a, b Failure) int { return cmp.Compare(a.ID, b.ID) })
+	return list
+}
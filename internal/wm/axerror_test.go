@@ -0,0 +1,68 @@
+package wm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFailuresBackOff(t *testing.T) {
+	var log strings.Builder
+	f := NewFailures(&log)
+	now := time.Unix(0, 0)
+	f.now = func() time.Time { return now }
+
+	// A step either lets time pass or records a result
+	type step struct {
+		fail    error // nil records a success
+		after   time.Duration
+		skipped bool // whether the window is skipped after the step
+	}
+	steps := []step{
+		{fail: AXErrInvalidUIElement},
+		{fail: AXErrCannotComplete, skipped: true},
+		{after: 499 * time.Millisecond, skipped: true},
+		{after: time.Millisecond},
+		{fail: AXErrCannotComplete, skipped: true},
+		{after: 999 * time.Millisecond, skipped: true},
+		{after: time.Millisecond},
+		{fail: AXErrCannotComplete, skipped: true},
+		{after: 2 * time.Second},
+		{fail: nil},
+		{fail: AXErrCannotComplete, skipped: true},
+		{after: 500 * time.Millisecond},
+	}
+	for i, st := range steps {
+		now = now.Add(st.after)
+		if st.after == 0 {
+			if st.fail != nil {
+				f.Fail(10, 1, "SetFrame", st.fail)
+			} else {
+				f.OK(1)
+			}
+		}
+		if got := f.Skip(1); got != st.skipped {
+			t.Errorf("step %d: Skip = %v, want %v", i, got, st.skipped)
+		}
+	}
+	if !strings.Contains(log.String(), "failed 3 times in a row") {
+		t.Errorf("repeated failures not logged: %q", log.String())
+	}
+}
+
+func TestFailuresBackOffCap(t *testing.T) {
+	f := NewFailures(&strings.Builder{})
+	now := time.Unix(0, 0)
+	f.now = func() time.Time { return now }
+	for range 20 {
+		f.Fail(10, 1, "SetFrame", AXErrCannotComplete)
+	}
+	now = now.Add(backoffMax - time.Millisecond)
+	if !f.Skip(1) {
+		t.Errorf("not skipped within the longest backoff")
+	}
+	now = now.Add(time.Millisecond)
+	if f.Skip(1) {
+		t.Errorf("backoff grew past %v", backoffMax)
+	}
+}
@@ -0,0 +1,142 @@
+package wm
+
+import (
+	"errors"
+)
+
+// Backend is everything mosaico needs from the window system. The macOS
+// implementation lives behind the darwin build tag; package fake has an
+// in-memory one for tests.
+type Backend interface {
+	// Windows lists the on-screen windows of regular apps, on every layer.
+	// Minimized windows and those of hidden apps are off screen, so they
+	// aren't listed, save one caught mid-animation. Use
+	// WindowInfo.Tileable to pick the ones to lay out.
+	Windows() ([]WindowInfo, error)
+	// AppWindows lists the on-screen windows of one app, like Windows.
+	AppWindows(pid uint32) ([]WindowInfo, error)
+	// WindowIDs lists every normal-layer window, including hidden and
+	// minimized ones, so callers can tell closed windows from parked ones.
+	WindowIDs() (map[uint32]bool, error)
+	// Displays returns the active displays ordered left to right.
+	Displays() ([]Display, error)
+	// ScreenBounds returns the size of the main display.
+	ScreenBounds() (width, height float64, err error)
+
+	// SetFrame moves and resizes a window and returns the frame it actually
+	// ended up with.
+	SetFrame(pid, id uint32, f Frame) (Frame, error)
+	Frame(pid, id uint32) (Frame, error)
+	SetPosition(pid, id uint32, x, y float64) error
+	SetMinimized(pid, id uint32, minimized bool) error
+	// Forget drops any state cached for a closed window.
+	Forget(id uint32)
+
+	HideApp(pid uint32)
+	UnhideApp(pid uint32)
+	// Focus activates the app and raises the window.
+	Focus(pid, id uint32) error
+	// Close presses the window's close button. The app may still keep the
+	// window open, for instance to ask about unsaved changes.
+	Close(pid, id uint32) error
+	// Quit asks the app to quit, or kills it when force is set.
+	Quit(pid uint32, force bool) error
+
+	// Failures lists the windows whose operations keep failing.
+	Failures() []Failure
+}
+
+// ErrUnsupported is returned by NewNative on platforms without a backend.
+var ErrUnsupported = errors.New("no window manager backend for this platform")
+
+type WindowInfo struct {
+	ID        uint32 // kCGWindowNumber
+	PID       uint32 // kCGWindowOwnerPID
+	BundleID  string
+	Title     string // AXTitle, or kCGWindowName when AX has none
+	OwnerName string // kCGWindowOwnerName
+	X         float64
+	Y         float64
+	Width     float64
+	Height    float64
+
+	Layer      int    // kCGWindowLayer; 0 for normal windows
+	Role       string // AXRole
+	Subrole    string // AXSubrole
+	Minimized  bool   // AXMinimized
+	Fullscreen bool
+	// Standard is set for regular document windows (AXWindow with the
+	// AXStandardWindow subrole), as opposed to dialogs, panels and popovers.
+	Standard bool
+}
+
+// Tileable reports whether the window belongs in a strip. Windows whose AX
+// attributes couldn't be read are given the benefit of the doubt.
+func (w WindowInfo) Tileable() bool {
+	if w.Layer != 0 || w.Minimized || w.Fullscreen {
+		return false
+	}
+	return w.Standard || w.Role == ""
+}
+
+// DisplayName is the title, or the app name for untitled windows.
+func (w WindowInfo) DisplayName() string {
+	if w.Title != "" {
+		return w.Title
+	}
+	return w.OwnerName
+}
+
+type Frame struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// Display is an active display in global coordinates (origin at the
+// top-left of the main display, the same space AXPosition uses).
+type Display struct {
+	ID     uint32
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	Main   bool
+	// Visible is the part not covered by the menu bar and Dock, like
+	// NSScreen's visibleFrame. Zero when unknown.
+	Visible Frame
+}
+
+// Usable returns the visible frame, or the whole display when it is
+// unknown.
+func (d Display) Usable() Frame {
+	if d.Visible.Width > 0 && d.Visible.Height > 0 {
+		return d.Visible
+	}
+	return Frame{X: d.X, Y: d.Y, Width: d.Width, Height: d.Height}
+}
+
+// Contains reports whether the point lies on the display.
+func (d Display) Contains(x, y float64) bool {
+	return x >= d.X && x < d.X+d.Width && y >= d.Y && y < d.Y+d.Height
+}
+
+// DisplayForPoint returns the display containing the point, falling back
+// to the main display when the point is off every screen.
+func DisplayForPoint(displays []Display, x, y float64) Display {
+	for _, d := range displays {
+		if d.Contains(x, y) {
+			return d
+		}
+	}
+	for _, d := range displays {
+		if d.Main {
+			return d
+		}
+	}
+	if len(displays) > 0 {
+		return displays[0]
+	}
+	return Display{}
+}
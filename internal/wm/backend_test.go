@@ -0,0 +1,28 @@
+package wm
+
+import "testing"
+
+func TestTileable(t *testing.T) {
+	standard := WindowInfo{Role: "AXWindow", Subrole: "AXStandardWindow", Standard: true}
+	tests := []struct {
+		name string
+		win  func(w *WindowInfo)
+		want bool
+	}{
+		{"standard window", func(w *WindowInfo) {}, true},
+		{"no AX element", func(w *WindowInfo) { *w = WindowInfo{} }, true},
+		{"dialog", func(w *WindowInfo) { w.Subrole, w.Standard = "AXDialog", false }, false},
+		{"overlay layer", func(w *WindowInfo) { w.Layer = 25 }, false},
+		{"minimized", func(w *WindowInfo) { w.Minimized = true }, false},
+		{"fullscreen", func(w *WindowInfo) { w.Fullscreen = true }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := standard
+			tt.win(&w)
+			if got := w.Tileable(); got != tt.want {
+				t.Errorf("Tileable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,576 @@
+//go:build darwin && cgo
+
+package wm
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation -framework ApplicationServices
+
+#include <CoreGraphics/CoreGraphics.h>
+#include <CoreFoundation/CoreFoundation.h>
+#include <ApplicationServices/ApplicationServices.h>
+*/
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework AppKit
+
+#import <AppKit/AppKit.h>
+
+char* getBundleIDForPID(pid_t pid) {
+	NSRunningApplication *app = [NSRunningApplication
+runningApplicationWithProcessIdentifier:pid];
+	if (app == nil) return NULL;
+	const char *bundleID = [app.bundleIdentifier UTF8String];
+	if (bundleID == NULL) return NULL;
+	return strdup(bundleID);
+}
+
+void hideApp(pid_t pid) {
+	NSRunningApplication *app = [NSRunningApplication runningApplicationWithProcessIdentifier:pid];
+	[app hide];
+}
+
+void unhideApp(pid_t pid) {
+	NSRunningApplication *app = [NSRunningApplication runningApplicationWithProcessIdentifier:pid];
+	[app unhide];
+}
+
+void focusApp(pid_t pid) {
+	NSRunningApplication *app = [NSRunningApplication runningApplicationWithProcessIdentifier:pid];
+	[app activateWithOptions:NSApplicationActivateIgnoringOtherApps];
+}
+
+// getVisibleFrame returns a display's visibleFrame, flipped from Cocoa's
+// bottom-left origin to the top-left one CoreGraphics and AX use.
+bool getVisibleFrame(CGDirectDisplayID display, CGRect *out) {
+	NSArray<NSScreen *> *screens = [NSScreen screens];
+	if (screens.count == 0) return false;
+	CGFloat primaryHeight = screens[0].frame.size.height;
+	for (NSScreen *screen in screens) {
+		NSNumber *number = screen.deviceDescription[@"NSScreenNumber"];
+		if (number.unsignedIntValue != display) continue;
+		NSRect frame = screen.visibleFrame;
+		out->origin.x = frame.origin.x;
+		out->origin.y = primaryHeight - frame.origin.y - frame.size.height;
+		out->size.width = frame.size.width;
+		out->size.height = frame.size.height;
+		return true;
+	}
+	return false;
+}
+
+bool quitApp(pid_t pid, bool force) {
+	NSRunningApplication *app = [NSRunningApplication runningApplicationWithProcessIdentifier:pid];
+	if (app == nil) return NO;
+	return force ? [app forceTerminate] : [app terminate];
+}
+
+// Private, but the only way to map an AX window to its CGWindowID.
+extern AXError _AXUIElementGetWindow(AXUIElementRef element, CGWindowID *identifier);
+
+AXError getWindowID(AXUIElementRef element, CGWindowID *identifier) {
+	return _AXUIElementGetWindow(element, identifier);
+}
+*/
+import "C"
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+	"unsafe"
+)
+
+// messagingTimeout is how long an AX call waits for an app before failing
+// with ErrCannotComplete. The system default, about 6 s, would hold up every
+// layout behind one hung app.
+const messagingTimeout = 250 * time.Millisecond
+
+// native is the macOS backend: CoreGraphics for listing, Accessibility for
+// moving, AppKit for app visibility.
+type native struct {
+	// elements maps CGWindowIDs to their AX window elements.
+	elements map[uint32]C.AXUIElementRef
+	failures *Failures
+}
+
+// NewNative returns the platform's window manager backend, which reports
+// windows that keep failing to log.
+func NewNative(log io.Writer) (Backend, error) {
+	// Set on the system-wide element, the timeout applies to every element
+	system := C.AXUIElementCreateSystemWide()
+	C.AXUIElementSetMessagingTimeout(system, C.float(messagingTimeout.Seconds()))
+	C.CFRelease(C.CFTypeRef(system))
+	return &native{elements: make(map[uint32]C.AXUIElementRef), failures: NewFailures(log)}, nil
+}
+
+// axError maps an AXError result to a Go error, nil on success.
+func axError(result C.AXError) error {
+	if result == C.kAXErrorSuccess {
+		return nil
+	}
+	return AXError(result)
+}
+
+// do runs an operation on a window's AX element. A stale element is
+// evicted from the cache and looked up again, once. Callers hold the strips
+// locked, so an app that did not respond is not waited for: its window is
+// skipped until Failures says to try again. Failures are recorded for
+// diagnostics.
+func (n *native) do(pid, id uint32, op string, f func(window C.AXUIElementRef) error) error {
+	if n.failures.Skip(id) {
+		return fmt.Errorf("%s window %d: %w", op, id, ErrBackingOff)
+	}
+	var err error
+	for range 2 {
+		var window C.AXUIElementRef
+		if window, err = n.element(pid, id); err == nil {
+			err = f(window)
+		}
+		if err == nil {
+			n.failures.OK(id)
+			return nil
+		}
+		if !Transient(err) {
+			break
+		}
+		n.Forget(id)
+	}
+	n.failures.Fail(pid, id, op, err)
+	return fmt.Errorf("%s window %d: %w", op, id, err)
+}
+
+func (n *native) Failures() []Failure {
+	return n.failures.List()
+}
+
+func (n *native) Windows() ([]WindowInfo, error) {
+	return n.windows(0)
+}
+
+func (n *native) AppWindows(pid uint32) ([]WindowInfo, error) {
+	return n.windows(pid)
+}
+
+// windows lists the on-screen windows of the app pid, or of every app
+// when pid is 0.
+func (n *native) windows(only uint32) ([]WindowInfo, error) {
+	windowList := C.CGWindowListCopyWindowInfo(C.kCGWindowListOptionOnScreenOnly, C.kCGNullWindowID)
+	if windowList == 0 {
+		return nil, fmt.Errorf("failed to get window list")
+	}
+	defer C.CFRelease(C.CFTypeRef(windowList))
+
+	var windows []WindowInfo
+	count := C.CFArrayGetCount(windowList)
+	for i := range count {
+		dict := C.CFDictionaryRef(C.CFArrayGetValueAtIndex(windowList, i))
+		pid := getIntValue(dict, C.kCGWindowOwnerPID)
+		if only != 0 && uint32(pid) != only {
+			continue
+		}
+		bundleID := getBundleID(uint32(pid))
+		if bundleID == "" {
+			continue // skip system processes without bundle IDs
+		}
+
+		x, y, w, h := getWindowBounds(dict)
+		info := WindowInfo{
+			ID:        uint32(getIntValue(dict, C.kCGWindowNumber)),
+			PID:       uint32(pid),
+			BundleID:  bundleID,
+			Title:     getStringValue(dict, C.kCGWindowName),
+			OwnerName: getStringValue(dict, C.kCGWindowOwnerName),
+			X:         x,
+			Y:         y,
+			Width:     w,
+			Height:    h,
+			Layer:     getIntValue(dict, C.kCGWindowLayer),
+		}
+		// Menu bar extras and overlays have no AX window worth asking about
+		if info.Layer == 0 {
+			n.readAXInfo(&info)
+		}
+		windows = append(windows, info)
+	}
+
+	return windows, nil
+}
+
+// readAXInfo fills in the metadata only Accessibility knows about.
+// kCGWindowName needs Screen Recording permission, so AXTitle wins.
+// Windows without an AX element are common (other Spaces, some helper
+// apps), so failures here aren't recorded.
+func (n *native) readAXInfo(info *WindowInfo) {
+	window, err := n.element(info.PID, info.ID)
+	if err != nil {
+		return
+	}
+	// A cached element for a window the app recreated goes stale
+	if role, err := getAttribute(window, "AXRole"); errors.Is(err, ErrInvalidElement) {
+		n.Forget(info.ID)
+		if window, err = n.element(info.PID, info.ID); err != nil {
+			return
+		}
+	} else if err == nil {
+		C.CFRelease(role)
+	}
+	if title := getStringAttribute(window, "AXTitle"); title != "" {
+		info.Title = title
+	}
+	info.Role = getStringAttribute(window, "AXRole")
+	info.Subrole = getStringAttribute(window, "AXSubrole")
+	info.Minimized = getBoolAttribute(window, "AXMinimized")
+	info.Fullscreen = getBoolAttribute(window, "AXFullScreen")
+	info.Standard = info.Role == "AXWindow" && info.Subrole == "AXStandardWindow"
+}
+
+func (n *native) WindowIDs() (map[uint32]bool, error) {
+	windowList := C.CGWindowListCopyWindowInfo(C.kCGWindowListOptionAll|C.kCGWindowListExcludeDesktopElements, C.kCGNullWindowID)
+	if windowList == 0 {
+		return nil, fmt.Errorf("failed to get window list")
+	}
+	defer C.CFRelease(C.CFTypeRef(windowList))
+
+	ids := make(map[uint32]bool)
+	count := C.CFArrayGetCount(windowList)
+	for i := range count {
+		dict := C.CFDictionaryRef(C.CFArrayGetValueAtIndex(windowList, i))
+		if getIntValue(dict, C.kCGWindowLayer) != 0 {
+			continue
+		}
+		ids[uint32(getIntValue(dict, C.kCGWindowNumber))] = true
+	}
+	return ids, nil
+}
+
+// element returns the AX element for a specific window of an app. Every
+// window of the app is cached on the way.
+func (n *native) element(pid, id uint32) (C.AXUIElementRef, error) {
+	if win, ok := n.elements[id]; ok {
+		return win, nil
+	}
+
+	app := C.AXUIElementCreateApplication(C.pid_t(pid))
+	defer C.CFRelease(C.CFTypeRef(app))
+	windows, err := getAttribute(app, "AXWindows")
+	if err != nil {
+		return 0, err
+	}
+	defer C.CFRelease(windows)
+
+	windowArray := C.CFArrayRef(windows)
+	for i := range C.CFArrayGetCount(windowArray) {
+		elem := C.AXUIElementRef(C.CFArrayGetValueAtIndex(windowArray, i))
+		var wid C.CGWindowID
+		if C.getWindowID(elem, &wid) != 0 {
+			continue
+		}
+		if _, ok := n.elements[uint32(wid)]; !ok {
+			C.CFRetain(C.CFTypeRef(elem))
+			n.elements[uint32(wid)] = elem
+		}
+	}
+
+	if win, ok := n.elements[id]; ok {
+		return win, nil
+	}
+	return 0, fmt.Errorf("no AX window %d for pid %d: %w", id, pid, ErrInvalidElement)
+}
+
+func (n *native) Forget(id uint32) {
+	if win, ok := n.elements[id]; ok {
+		C.CFRelease(C.CFTypeRef(win))
+		delete(n.elements, id)
+	}
+}
+
+func (n *native) SetFrame(pid, id uint32, f Frame) (Frame, error) {
+	var actual Frame
+	err := n.do(pid, id, "SetFrame", func(window C.AXUIElementRef) error {
+		// Size, position, size: shrinking first lets the window move to spots
+		// where its old size wouldn't fit, and the second resize catches apps
+		// that clamp the size against the screen edge.
+		if err := setSize(window, f.Width, f.Height); err != nil {
+			return err
+		}
+		if err := setPosition(window, f.X, f.Y); err != nil {
+			return err
+		}
+		if err := setSize(window, f.Width, f.Height); err != nil {
+			return err
+		}
+		var err error
+		actual, err = getFrame(window)
+		return err
+	})
+	return actual, err
+}
+
+func (n *native) Frame(pid, id uint32) (Frame, error) {
+	var f Frame
+	err := n.do(pid, id, "Frame", func(window C.AXUIElementRef) error {
+		var err error
+		f, err = getFrame(window)
+		return err
+	})
+	return f, err
+}
+
+func getFrame(window C.AXUIElementRef) (Frame, error) {
+	posRef, err := getAttribute(window, "AXPosition")
+	if err != nil {
+		return Frame{}, err
+	}
+	defer C.CFRelease(posRef)
+	sizeRef, err := getAttribute(window, "AXSize")
+	if err != nil {
+		return Frame{}, err
+	}
+	defer C.CFRelease(sizeRef)
+
+	var point C.CGPoint
+	var size C.CGSize
+	C.AXValueGetValue(C.AXValueRef(posRef), C.kAXValueTypeCGPoint, unsafe.Pointer(&point))
+	C.AXValueGetValue(C.AXValueRef(sizeRef), C.kAXValueTypeCGSize, unsafe.Pointer(&size))
+
+	return Frame{
+		X:      float64(point.x),
+		Y:      float64(point.y),
+		Width:  float64(size.width),
+		Height: float64(size.height),
+	}, nil
+}
+
+func (n *native) SetPosition(pid, id uint32, x, y float64) error {
+	return n.do(pid, id, "SetPosition", func(window C.AXUIElementRef) error {
+		return setPosition(window, x, y)
+	})
+}
+
+func (n *native) SetMinimized(pid, id uint32, minimized bool) error {
+	value := C.kCFBooleanFalse
+	if minimized {
+		value = C.kCFBooleanTrue
+	}
+	return n.do(pid, id, "SetMinimized", func(window C.AXUIElementRef) error {
+		attr := createCFString("AXMinimized")
+		defer C.CFRelease(C.CFTypeRef(attr))
+		return axError(C.AXUIElementSetAttributeValue(window, attr, C.CFTypeRef(value)))
+	})
+}
+
+func setPosition(window C.AXUIElementRef, x, y float64) error {
+	var point C.CGPoint
+	point.x = C.CGFloat(x)
+	point.y = C.CGFloat(y)
+	posValue := C.AXValueCreate(C.kAXValueTypeCGPoint, unsafe.Pointer(&point))
+	posAttr := createCFString("AXPosition")
+	defer C.CFRelease(C.CFTypeRef(posAttr))
+	defer C.CFRelease(C.CFTypeRef(unsafe.Pointer(posValue)))
+	return axError(C.AXUIElementSetAttributeValue(window, posAttr, C.CFTypeRef(unsafe.Pointer(posValue))))
+}
+
+func setSize(window C.AXUIElementRef, w, h float64) error {
+	var size C.CGSize
+	size.width = C.CGFloat(w)
+	size.height = C.CGFloat(h)
+	sizeValue := C.AXValueCreate(C.kAXValueTypeCGSize, unsafe.Pointer(&size))
+	sizeAttr := createCFString("AXSize")
+	defer C.CFRelease(C.CFTypeRef(sizeAttr))
+	defer C.CFRelease(C.CFTypeRef(unsafe.Pointer(sizeValue)))
+	return axError(C.AXUIElementSetAttributeValue(window, sizeAttr, C.CFTypeRef(unsafe.Pointer(sizeValue))))
+}
+
+func (n *native) ScreenBounds() (width, height float64, err error) {
+	mainDisplayID := C.CGMainDisplayID()
+	if mainDisplayID == 0 {
+		return 0, 0, fmt.Errorf("failed to get main display ID")
+	}
+
+	rect := C.CGDisplayBounds(mainDisplayID)
+	return float64(rect.size.width), float64(rect.size.height), nil
+}
+
+func (n *native) Displays() ([]Display, error) {
+	var count C.uint32_t
+	if C.CGGetActiveDisplayList(0, nil, &count) != 0 || count == 0 {
+		return nil, fmt.Errorf("failed to get display list")
+	}
+
+	ids := make([]C.CGDirectDisplayID, count)
+	if C.CGGetActiveDisplayList(count, &ids[0], &count) != 0 {
+		return nil, fmt.Errorf("failed to get display list")
+	}
+
+	mainID := C.CGMainDisplayID()
+	displays := make([]Display, 0, count)
+	for _, id := range ids[:count] {
+		rect := C.CGDisplayBounds(id)
+		disp := Display{
+			ID:     uint32(id),
+			X:      float64(rect.origin.x),
+			Y:      float64(rect.origin.y),
+			Width:  float64(rect.size.width),
+			Height: float64(rect.size.height),
+			Main:   id == mainID,
+		}
+		var visible C.CGRect
+		if C.getVisibleFrame(id, &visible) {
+			disp.Visible = Frame{
+				X:      float64(visible.origin.x),
+				Y:      float64(visible.origin.y),
+				Width:  float64(visible.size.width),
+				Height: float64(visible.size.height),
+			}
+		}
+		displays = append(displays, disp)
+	}
+
+	slices.SortFunc(displays, func(a, b Display) int {
+		if a.X != b.X {
+			return cmp.Compare(a.X, b.X)
+		}
+		return cmp.Compare(a.Y, b.Y)
+	})
+	return displays, nil
+}
+
+func (n *native) HideApp(pid uint32) {
+	fmt.Printf("HideApp PID=%d\n", pid)
+	C.hideApp(C.pid_t(pid))
+}
+
+func (n *native) UnhideApp(pid uint32) {
+	fmt.Printf("UnhideApp PID=%d\n", pid)
+	C.unhideApp(C.pid_t(pid))
+}
+
+func (n *native) Focus(pid, id uint32) error {
+	C.focusApp(C.pid_t(pid))
+	return n.do(pid, id, "Focus", func(window C.AXUIElementRef) error {
+		action := createCFString("AXRaise")
+		defer C.CFRelease(C.CFTypeRef(action))
+		return axError(C.AXUIElementPerformAction(window, action))
+	})
+}
+
+func (n *native) Close(pid, id uint32) error {
+	return n.do(pid, id, "Close", func(window C.AXUIElementRef) error {
+		button, err := getAttribute(window, "AXCloseButton")
+		if err != nil {
+			return err
+		}
+		defer C.CFRelease(button)
+		action := createCFString("AXPress")
+		defer C.CFRelease(C.CFTypeRef(action))
+		return axError(C.AXUIElementPerformAction(C.AXUIElementRef(button), action))
+	})
+}
+
+func (n *native) Quit(pid uint32, force bool) error {
+	if !C.quitApp(C.pid_t(pid), C.bool(force)) {
+		return fmt.Errorf("app %d refused to quit", pid)
+	}
+	return nil
+}
+
+func createCFString(s string) C.CFStringRef {
+	cstr := C.CString(s)
+	defer C.free(unsafe.Pointer(cstr))
+	return C.CFStringCreateWithCString(C.kCFAllocatorDefault, cstr, C.kCFStringEncodingUTF8)
+}
+
+func getAttribute(elem C.AXUIElementRef, attr string) (C.CFTypeRef, error) {
+	cfAttr := createCFString(attr)
+	defer C.CFRelease(C.CFTypeRef(cfAttr))
+
+	var value C.CFTypeRef
+	if err := axError(C.AXUIElementCopyAttributeValue(elem, cfAttr, &value)); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+func getStringAttribute(elem C.AXUIElementRef, attr string) string {
+	value, err := getAttribute(elem, attr)
+	if err != nil {
+		return ""
+	}
+	defer C.CFRelease(value)
+	if C.CFGetTypeID(value) != C.CFStringGetTypeID() {
+		return ""
+	}
+	return goString(C.CFStringRef(value))
+}
+
+func getBoolAttribute(elem C.AXUIElementRef, attr string) bool {
+	value, err := getAttribute(elem, attr)
+	if err != nil {
+		return false
+	}
+	defer C.CFRelease(value)
+	if C.CFGetTypeID(value) != C.CFBooleanGetTypeID() {
+		return false
+	}
+	return C.CFBooleanGetValue(C.CFBooleanRef(value)) != 0
+}
+
+func getStringValue(dict C.CFDictionaryRef, key C.CFStringRef) string {
+	v := C.CFDictionaryGetValue(dict, unsafe.Pointer(key))
+	if v == nil {
+		return ""
+	}
+
+	return goString(C.CFStringRef(v))
+}
+
+func goString(cfString C.CFStringRef) string {
+	// Get string length and buffer
+	length := C.CFStringGetLength(cfString)
+	maxSize := C.CFStringGetMaximumSizeForEncoding(length, C.kCFStringEncodingUTF8) + 1
+
+	buffer := C.malloc(C.size_t(maxSize))
+	defer C.free(buffer)
+
+	C.CFStringGetCString(cfString, (*C.char)(buffer), maxSize, C.kCFStringEncodingUTF8)
+
+	return C.GoString((*C.char)(buffer))
+}
+
+func getIntValue(dict C.CFDictionaryRef, key C.CFStringRef) int {
+	v := C.CFDictionaryGetValue(dict, unsafe.Pointer(key))
+	if v == nil {
+		return 0
+	}
+
+	cfNumber := C.CFNumberRef(v)
+	var intValue C.int
+	C.CFNumberGetValue(cfNumber, C.kCFNumberIntType, unsafe.Pointer(&intValue))
+	return int(intValue)
+}
+
+func getWindowBounds(dict C.CFDictionaryRef) (x, y, width, height float64) {
+	boundsRef := C.CFDictionaryGetValue(dict, unsafe.Pointer(C.kCGWindowBounds))
+	if boundsRef == nil {
+		return
+	}
+
+	var rect C.CGRect
+	C.CGRectMakeWithDictionaryRepresentation(C.CFDictionaryRef(boundsRef), &rect)
+
+	return float64(rect.origin.x), float64(rect.origin.y),
+		float64(rect.size.width), float64(rect.size.height)
+}
+
+func getBundleID(pid uint32) string {
+	cstr := C.getBundleIDForPID(C.pid_t(pid))
+	if cstr == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(cstr))
+	return C.GoString(cstr)
+}
@@ -0,0 +1,54 @@
+package wm
+
+// EventKind is what happened to a window.
+type EventKind int
+
+const (
+	EventCreated EventKind = iota
+	EventDestroyed
+	EventMoved
+	EventResized
+	EventTitleChanged
+	EventFocused
+	// EventDisplaysChanged means the displays or their usable area changed,
+	// for instance when the Dock moved. PID and ID are 0.
+	EventDisplaysChanged
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventCreated:
+		return "created"
+	case EventDestroyed:
+		return "destroyed"
+	case EventMoved:
+		return "moved"
+	case EventResized:
+		return "resized"
+	case EventTitleChanged:
+		return "title-changed"
+	case EventFocused:
+		return "focused"
+	case EventDisplaysChanged:
+		return "displays-changed"
+	}
+	return "unknown"
+}
+
+// Event is a change to a window. ID is 0 for app-level events, such as an
+// app launching or quitting, or an app being activated when its focused
+// window is unknown.
+type Event struct {
+	Kind EventKind
+	PID  uint32
+	ID   uint32
+}
+
+// EventSource delivers window events as they happen. Events can be
+// dropped, so callers should still reconcile with Backend.Windows now and
+// then.
+type EventSource interface {
+	// Events returns the channel events arrive on. It is closed by Close.
+	Events() <-chan Event
+	Close() error
+}
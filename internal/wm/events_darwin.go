@@ -0,0 +1,276 @@
+//go:build darwin && cgo
+
+package wm
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework AppKit -framework ApplicationServices
+
+#import <AppKit/AppKit.h>
+#include <ApplicationServices/ApplicationServices.h>
+
+extern void goWindowEvent(int kind, int pid, unsigned int wid);
+extern AXError _AXUIElementGetWindow(AXUIElementRef element, CGWindowID *identifier);
+
+// Same order as wm.EventKind
+enum { evCreated, evDestroyed, evMoved, evResized, evTitleChanged, evFocused, evDisplaysChanged };
+
+static CFRunLoopRef eventLoop;
+static NSMutableDictionary *observers; // pid -> AXObserverRef
+static NSMutableArray *workspaceObservers;
+static NSOperationQueue *workspaceQueue;
+static id dockObserver;
+
+// refcons carry the pid and, for per-window registrations, the window ID,
+// since a destroyed element can no longer be asked for either.
+static void *packRefcon(pid_t pid, CGWindowID wid) {
+	return (void *)(((uintptr_t)pid << 32) | wid);
+}
+
+static void observeWindow(AXObserverRef observer, AXUIElementRef window, pid_t pid) {
+	CGWindowID wid = 0;
+	if (_AXUIElementGetWindow(window, &wid) != kAXErrorSuccess) return;
+	AXObserverAddNotification(observer, window, kAXUIElementDestroyedNotification, packRefcon(pid, wid));
+}
+
+static CGWindowID focusedWindow(pid_t pid) {
+	CGWindowID wid = 0;
+	AXUIElementRef app = AXUIElementCreateApplication(pid);
+	AXUIElementRef window = NULL;
+	if (AXUIElementCopyAttributeValue(app, kAXFocusedWindowAttribute, (CFTypeRef *)&window) == kAXErrorSuccess && window) {
+		_AXUIElementGetWindow(window, &wid);
+		CFRelease(window);
+	}
+	CFRelease(app);
+	return wid;
+}
+
+static void axCallback(AXObserverRef observer, AXUIElementRef element, CFStringRef notification, void *refcon) {
+	pid_t pid = (pid_t)((uintptr_t)refcon >> 32);
+	CGWindowID wid = (CGWindowID)((uintptr_t)refcon & 0xffffffff);
+	if (CFEqual(notification, kAXUIElementDestroyedNotification)) {
+		goWindowEvent(evDestroyed, pid, wid);
+		return;
+	}
+	if (_AXUIElementGetWindow(element, &wid) != kAXErrorSuccess) return; // not a window
+
+	if (CFEqual(notification, kAXWindowCreatedNotification)) {
+		observeWindow(observer, element, pid);
+		goWindowEvent(evCreated, pid, wid);
+	} else if (CFEqual(notification, kAXWindowMovedNotification)) {
+		goWindowEvent(evMoved, pid, wid);
+	} else if (CFEqual(notification, kAXWindowResizedNotification)) {
+		goWindowEvent(evResized, pid, wid);
+	} else if (CFEqual(notification, kAXTitleChangedNotification)) {
+		goWindowEvent(evTitleChanged, pid, wid);
+	} else if (CFEqual(notification, kAXFocusedWindowChangedNotification)) {
+		goWindowEvent(evFocused, pid, wid);
+	}
+}
+
+static void observeApp(pid_t pid) {
+	@synchronized (observers) {
+		if (observers[@(pid)] != nil) return;
+	}
+	AXObserverRef observer;
+	if (AXObserverCreate(pid, axCallback, &observer) != kAXErrorSuccess) return;
+
+	// Notifications registered on the app element cover all its windows
+	AXUIElementRef app = AXUIElementCreateApplication(pid);
+	CFStringRef names[] = {
+		kAXWindowCreatedNotification,
+		kAXWindowMovedNotification,
+		kAXWindowResizedNotification,
+		kAXTitleChangedNotification,
+		kAXFocusedWindowChangedNotification,
+	};
+	for (int i = 0; i < sizeof(names) / sizeof(names[0]); i++) {
+		AXObserverAddNotification(observer, app, names[i], packRefcon(pid, 0));
+	}
+	CFArrayRef windows = NULL;
+	if (AXUIElementCopyAttributeValue(app, kAXWindowsAttribute, (CFTypeRef *)&windows) == kAXErrorSuccess && windows) {
+		for (CFIndex i = 0; i < CFArrayGetCount(windows); i++) {
+			observeWindow(observer, (AXUIElementRef)CFArrayGetValueAtIndex(windows, i), pid);
+		}
+		CFRelease(windows);
+	}
+	CFRelease(app);
+
+	CFRunLoopAddSource(eventLoop, AXObserverGetRunLoopSource(observer), kCFRunLoopDefaultMode);
+	CFRunLoopWakeUp(eventLoop);
+	@synchronized (observers) {
+		observers[@(pid)] = (id)observer;
+	}
+	CFRelease(observer);
+}
+
+static void forgetApp(pid_t pid) {
+	@synchronized (observers) {
+		AXObserverRef observer = (AXObserverRef)observers[@(pid)];
+		if (observer == NULL) return;
+		CFRunLoopRemoveSource(eventLoop, AXObserverGetRunLoopSource(observer), kCFRunLoopDefaultMode);
+		[observers removeObjectForKey:@(pid)];
+	}
+}
+
+static void observeRegularApp(NSRunningApplication *app) {
+	if (app.activationPolicy == NSApplicationActivationPolicyRegular) {
+		observeApp(app.processIdentifier);
+	}
+}
+
+static void displaysReconfigured(CGDirectDisplayID display, CGDisplayChangeSummaryFlags flags, void *info) {
+	if (flags & kCGDisplayBeginConfigurationFlag) return;
+	goWindowEvent(evDisplaysChanged, 0, 0);
+}
+
+// startObserving registers AX observers on the current run loop for every
+// regular app, and NSWorkspace observers to follow apps launching,
+// quitting and activating. NSWorkspace posts its notifications from the
+// main run loop, so the program must keep that running, as the event tap
+// does. The blocks run on a queue of their own, so an app slow to answer
+// focusedWindow doesn't hold up the event tap on the main thread.
+static void startObserving(void) {
+	eventLoop = CFRunLoopGetCurrent();
+	observers = [[NSMutableDictionary alloc] init];
+	workspaceObservers = [[NSMutableArray alloc] init];
+	workspaceQueue = [[NSOperationQueue alloc] init];
+	workspaceQueue.maxConcurrentOperationCount = 1;
+	for (NSRunningApplication *app in [[NSWorkspace sharedWorkspace] runningApplications]) {
+		observeRegularApp(app);
+	}
+
+	NSNotificationCenter *nc = [[NSWorkspace sharedWorkspace] notificationCenter];
+	[workspaceObservers addObject:[nc addObserverForName:NSWorkspaceDidLaunchApplicationNotification object:nil queue:workspaceQueue
+		usingBlock:^(NSNotification *n) {
+			NSRunningApplication *app = n.userInfo[NSWorkspaceApplicationKey];
+			observeRegularApp(app);
+			goWindowEvent(evCreated, app.processIdentifier, 0);
+		}]];
+	[workspaceObservers addObject:[nc addObserverForName:NSWorkspaceDidTerminateApplicationNotification object:nil queue:workspaceQueue
+		usingBlock:^(NSNotification *n) {
+			NSRunningApplication *app = n.userInfo[NSWorkspaceApplicationKey];
+			forgetApp(app.processIdentifier);
+			goWindowEvent(evDestroyed, app.processIdentifier, 0);
+		}]];
+	[workspaceObservers addObject:[nc addObserverForName:NSWorkspaceDidActivateApplicationNotification object:nil queue:workspaceQueue
+		usingBlock:^(NSNotification *n) {
+			NSRunningApplication *app = n.userInfo[NSWorkspaceApplicationKey];
+			pid_t pid = app.processIdentifier;
+			goWindowEvent(evFocused, pid, focusedWindow(pid));
+		}]];
+
+	// Displays plugged, unplugged, rearranged or changing resolution
+	CGDisplayRegisterReconfigurationCallback(displaysReconfigured, NULL);
+
+	// The Dock moving, resizing or toggling auto-hide changes visibleFrame
+	dockObserver = [[NSDistributedNotificationCenter defaultCenter] addObserverForName:@"com.apple.dock.prefchanged"
+		object:nil queue:workspaceQueue usingBlock:^(NSNotification *n) {
+			goWindowEvent(evDisplaysChanged, 0, 0);
+		}];
+}
+
+static void stopObserving(void) {
+	CGDisplayRemoveReconfigurationCallback(displaysReconfigured, NULL);
+	NSNotificationCenter *nc = [[NSWorkspace sharedWorkspace] notificationCenter];
+	for (id o in workspaceObservers) {
+		[nc removeObserver:o];
+	}
+	[workspaceObservers removeAllObjects];
+	if (dockObserver != nil) {
+		[[NSDistributedNotificationCenter defaultCenter] removeObserver:dockObserver];
+		dockObserver = nil;
+	}
+	@synchronized (observers) {
+		for (NSNumber *pid in [observers allKeys]) {
+			AXObserverRef observer = (AXObserverRef)observers[pid];
+			CFRunLoopRemoveSource(eventLoop, AXObserverGetRunLoopSource(observer), kCFRunLoopDefaultMode);
+		}
+		[observers removeAllObjects];
+	}
+	CFRunLoopStop(eventLoop);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// eventSource is the macOS event source: AXObserver for window events,
+// NSWorkspace for apps launching, quitting and activating. The AX observers
+// run on their own thread and run loop, the NSWorkspace ones on their own
+// queue.
+type eventSource struct {
+	events chan Event
+	log    io.Writer
+}
+
+var (
+	eventsMu sync.Mutex
+	// current is the running source; C callbacks can't carry Go pointers.
+	current *eventSource
+	dropped int
+)
+
+// NewEventSource starts observing window events, logging dropped ones to
+// log. Only one source can run at a time. App launches, quits and
+// activations only arrive while the main run loop runs.
+func NewEventSource(log io.Writer) (EventSource, error) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if current != nil {
+		return nil, errors.New("event source already running")
+	}
+	src := &eventSource{events: make(chan Event, 256), log: log}
+	current = src
+
+	ready := make(chan struct{})
+	go func() {
+		runtime.LockOSThread()
+		C.startObserving()
+		close(ready)
+		C.CFRunLoopRun()
+	}()
+	<-ready
+	return src, nil
+}
+
+func (s *eventSource) Events() <-chan Event {
+	return s.events
+}
+
+func (s *eventSource) Close() error {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if current != s {
+		return nil
+	}
+	C.stopObserving()
+	current = nil
+	close(s.events)
+	return nil
+}
+
+//export goWindowEvent
+func goWindowEvent(kind C.int, pid C.int, wid C.uint) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if current == nil {
+		return
+	}
+	e := Event{Kind: EventKind(kind), PID: uint32(pid), ID: uint32(wid)}
+	select {
+	case current.events <- e:
+	default:
+		// The reconciliation poll catches up on whatever was missed
+		dropped++
+		if dropped%100 == 1 {
+			fmt.Fprintf(current.log, "WARNING: event queue full, dropped %d events\n", dropped)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package fake
+
+import (
+	"sync"
+	"time"
+
+	"github.com/machina/mosaico/internal/wm"
+)
+
+// EventSource is a wm.EventSource driven by tests.
+type EventSource struct {
+	events chan wm.Event
+	once   sync.Once
+}
+
+func NewEventSource() *EventSource {
+	return &EventSource{events: make(chan wm.Event, 64)}
+}
+
+func (s *EventSource) Events() <-chan wm.Event {
+	return s.events
+}
+
+func (s *EventSource) Close() error {
+	s.once.Do(func() { close(s.events) })
+	return nil
+}
+
+// Send delivers events in order. It blocks while the buffer is full.
+func (s *EventSource) Send(events ...wm.Event) {
+	for _, e := range events {
+		s.events <- e
+	}
+}
+
+// Step is one scripted event, delivered After the previous one.
+type Step struct {
+	After time.Duration
+	Event wm.Event
+}
+
+// Play delivers a script in the background. The returned channel is
+// closed once every step has been sent.
+func (s *EventSource) Play(script ...Step) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, step := range script {
+			time.Sleep(step.After)
+			s.Send(step.Event)
+		}
+	}()
+	return done
+}
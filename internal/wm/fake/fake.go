@@ -0,0 +1,344 @@
+// Package fake is an in-memory wm.Backend. It simulates windows, displays
+// and app visibility, and records every call so tests can assert on them.
+package fake
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/machina/mosaico/internal/wm"
+)
+
+// Window is a simulated window. Minimized windows are off screen, like
+// real ones. MinWidth and MinHeight make SetFrame refuse smaller sizes,
+// like apps with a minimum window size do.
+type Window struct {
+	wm.WindowInfo
+	MinWidth  float64
+	MinHeight float64
+}
+
+// Call is one recorded backend call.
+type Call struct {
+	Method string
+	PID    uint32
+	ID     uint32
+	Frame  wm.Frame
+	Bool   bool
+}
+
+func (c Call) String() string {
+	return fmt.Sprintf("%s(pid=%d id=%d frame=%+v bool=%v)", c.Method, c.PID, c.ID, c.Frame, c.Bool)
+}
+
+type Backend struct {
+	mu       sync.Mutex
+	windows  []*Window
+	displays []wm.Display
+	hidden   map[uint32]bool
+	focused  uint32
+	calls    []Call
+	// errs makes operations on a window fail, see Fail.
+	errs     map[uint32]error
+	failures *wm.Failures
+}
+
+// New returns a backend with the given displays, or a single 2560x1440
+// main display when none are given.
+func New(displays ...wm.Display) *Backend {
+	if len(displays) == 0 {
+		displays = []wm.Display{{ID: 1, Width: 2560, Height: 1440, Main: true}}
+	}
+	return &Backend{
+		displays: displays,
+		hidden:   make(map[uint32]bool),
+		errs:     make(map[uint32]error),
+		failures: wm.NewFailures(io.Discard),
+	}
+}
+
+// AddWindow simulates a window opening.
+func (b *Backend) AddWindow(w Window) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	win := w
+	b.windows = append(b.windows, &win)
+}
+
+// RemoveWindow simulates a window closing.
+func (b *Backend) RemoveWindow(id uint32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.windows = slices.DeleteFunc(b.windows, func(w *Window) bool { return w.ID == id })
+}
+
+// MoveWindow simulates the user moving or resizing a window.
+func (b *Backend) MoveWindow(id uint32, f wm.Frame) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w := b.find(id); w != nil {
+		w.X, w.Y, w.Width, w.Height = f.X, f.Y, f.Width, f.Height
+	}
+}
+
+// SetDisplays simulates plugging and unplugging displays.
+func (b *Backend) SetDisplays(displays ...wm.Display) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.displays = displays
+}
+
+// Fail makes every operation on the window return err, such as
+// wm.AXErrCannotComplete, until called again with nil.
+func (b *Backend) Fail(id uint32, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.errs, id)
+		return
+	}
+	b.errs[id] = err
+}
+
+// Window returns the current state of a simulated window.
+func (b *Backend) Window(id uint32) (Window, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w := b.find(id); w != nil {
+		return *w, true
+	}
+	return Window{}, false
+}
+
+// Hidden reports whether an app is hidden.
+func (b *Backend) Hidden(pid uint32) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.hidden[pid]
+}
+
+// Focused returns the ID of the last focused window.
+func (b *Backend) Focused() uint32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.focused
+}
+
+// Calls returns the recorded calls.
+func (b *Backend) Calls() []Call {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.calls)
+}
+
+// ResetCalls forgets the recorded calls.
+func (b *Backend) ResetCalls() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = nil
+}
+
+func (b *Backend) record(c Call) {
+	b.calls = append(b.calls, c)
+}
+
+func (b *Backend) find(id uint32) *Window {
+	for _, w := range b.windows {
+		if w.ID == id {
+			return w
+		}
+	}
+	return nil
+}
+
+func (b *Backend) lookup(pid, id uint32, op string) (*Window, error) {
+	w := b.find(id)
+	if w == nil || w.PID != pid {
+		err := fmt.Errorf("no window %d for pid %d: %w", id, pid, wm.ErrInvalidElement)
+		b.failures.Fail(pid, id, op, err)
+		return nil, err
+	}
+	if b.failures.Skip(id) {
+		return nil, fmt.Errorf("%s window %d: %w", op, id, wm.ErrBackingOff)
+	}
+	if err := b.errs[id]; err != nil {
+		b.failures.Fail(pid, id, op, err)
+		return nil, fmt.Errorf("%s window %d: %w", op, id, err)
+	}
+	b.failures.OK(id)
+	return w, nil
+}
+
+func (b *Backend) Windows() ([]wm.WindowInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "Windows"})
+	return b.onScreen(0), nil
+}
+
+func (b *Backend) AppWindows(pid uint32) ([]wm.WindowInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "AppWindows", PID: pid})
+	return b.onScreen(pid), nil
+}
+
+// onScreen lists the windows of the app pid, or of every app when pid
+// is 0, that aren't minimized or hidden.
+func (b *Backend) onScreen(pid uint32) []wm.WindowInfo {
+	var infos []wm.WindowInfo
+	for _, w := range b.windows {
+		if w.Minimized || b.hidden[w.PID] || (pid != 0 && w.PID != pid) {
+			continue
+		}
+		infos = append(infos, w.WindowInfo)
+	}
+	return infos
+}
+
+func (b *Backend) WindowIDs() (map[uint32]bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "WindowIDs"})
+
+	ids := make(map[uint32]bool)
+	for _, w := range b.windows {
+		ids[w.ID] = true
+	}
+	return ids, nil
+}
+
+func (b *Backend) Displays() ([]wm.Display, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "Displays"})
+	if len(b.displays) == 0 {
+		return nil, fmt.Errorf("no displays")
+	}
+	return slices.Clone(b.displays), nil
+}
+
+func (b *Backend) ScreenBounds() (width, height float64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "ScreenBounds"})
+	for _, d := range b.displays {
+		if d.Main {
+			return d.Width, d.Height, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no main display")
+}
+
+func (b *Backend) SetFrame(pid, id uint32, f wm.Frame) (wm.Frame, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "SetFrame", PID: pid, ID: id, Frame: f})
+
+	w, err := b.lookup(pid, id, "SetFrame")
+	if err != nil {
+		return wm.Frame{}, err
+	}
+	w.X, w.Y = f.X, f.Y
+	w.Width, w.Height = max(f.Width, w.MinWidth), max(f.Height, w.MinHeight)
+	return wm.Frame{X: w.X, Y: w.Y, Width: w.Width, Height: w.Height}, nil
+}
+
+func (b *Backend) Frame(pid, id uint32) (wm.Frame, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "Frame", PID: pid, ID: id})
+
+	w, err := b.lookup(pid, id, "Frame")
+	if err != nil {
+		return wm.Frame{}, err
+	}
+	return wm.Frame{X: w.X, Y: w.Y, Width: w.Width, Height: w.Height}, nil
+}
+
+func (b *Backend) SetPosition(pid, id uint32, x, y float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "SetPosition", PID: pid, ID: id, Frame: wm.Frame{X: x, Y: y}})
+
+	w, err := b.lookup(pid, id, "SetPosition")
+	if err != nil {
+		return err
+	}
+	w.X, w.Y = x, y
+	return nil
+}
+
+func (b *Backend) SetMinimized(pid, id uint32, minimized bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "SetMinimized", PID: pid, ID: id, Bool: minimized})
+
+	w, err := b.lookup(pid, id, "SetMinimized")
+	if err != nil {
+		return err
+	}
+	w.Minimized = minimized
+	return nil
+}
+
+func (b *Backend) Forget(id uint32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "Forget", ID: id})
+}
+
+func (b *Backend) HideApp(pid uint32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "HideApp", PID: pid})
+	b.hidden[pid] = true
+}
+
+func (b *Backend) UnhideApp(pid uint32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "UnhideApp", PID: pid})
+	delete(b.hidden, pid)
+}
+
+func (b *Backend) Focus(pid, id uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "Focus", PID: pid, ID: id})
+
+	if _, err := b.lookup(pid, id, "Focus"); err != nil {
+		return err
+	}
+	delete(b.hidden, pid)
+	b.focused = id
+	return nil
+}
+
+func (b *Backend) Failures() []wm.Failure {
+	return b.failures.List()
+}
+
+func (b *Backend) Close(pid, id uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "Close", PID: pid, ID: id})
+
+	if _, err := b.lookup(pid, id, "Close"); err != nil {
+		return err
+	}
+	b.windows = slices.DeleteFunc(b.windows, func(w *Window) bool { return w.ID == id })
+	return nil
+}
+
+func (b *Backend) Quit(pid uint32, force bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(Call{Method: "Quit", PID: pid, Bool: force})
+
+	b.windows = slices.DeleteFunc(b.windows, func(w *Window) bool { return w.PID == pid })
+	delete(b.hidden, pid)
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !darwin || !cgo
+
+package wm
+
+import "io"
+
+// NewNative returns the platform's window manager backend.
+func NewNative(log io.Writer) (Backend, error) {
+	return nil, ErrUnsupported
+}
+
+// NewEventSource returns the platform's window event source.
+func NewEventSource(log io.Writer) (EventSource, error) {
+	return nil, ErrUnsupported
+}